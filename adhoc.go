@@ -0,0 +1,188 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"crypto/sha256"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// adHocSession is a session parsed from the first parameter of a metric.
+type adHocSession struct {
+	session *Session
+	// lastAccess is the last use in Unix nanoseconds, it is updated atomically under the read lock.
+	lastAccess int64
+}
+
+// adHocKey identifies a cached session by a hash of its URI, so passwords embedded in URIs are not kept as keys
+// of the cache. URIs are hashed as given since a URI without a scheme is not accepted like its normalized form.
+type adHocKey [sha256.Size]byte
+
+func newAdHocKey(uri string) adHocKey {
+	return sha256.Sum256([]byte(uri))
+}
+
+// touch updates the last use of a session.
+func (s *adHocSession) touch() {
+	atomic.StoreInt64(&s.lastAccess, time.Now().UnixNano())
+}
+
+// lastTimeAccess returns the last use of a session.
+func (s *adHocSession) lastTimeAccess() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastAccess))
+}
+
+// parseAdHocSession parses a URI or a DSN of the driver given instead of a session name.
+// Credentials embedded in it are moved to the session's user and password and its parameters to the session's params,
+// a database cannot be selected by the session.
+func parseAdHocSession(uri string) (*Session, error) {
+	session := &Session{}
+
+	if config, err := mysql.ParseDSN(uri); err == nil && !strings.Contains(uri, "://") && len(config.Addr) > 0 {
+		if len(config.DBName) > 0 {
+			return nil, errorAdHocDatabase
+		}
+
+		// A path to a Unix-socket starts with a slash, so unix:///path/to/socket is built in this case too.
+		session.Uri = config.Net + "://" + config.Addr
+		if config.Net == pipeNet {
+			session.Uri = pipeNet + ":///" + config.Addr
+		}
+		session.User = config.User
+		session.Password = config.Passwd
+
+		// Parameters follow the last slash like the driver parses them, so a password may contain a question mark.
+		tail := uri[strings.LastIndex(uri, "/")+1:]
+		if i := strings.Index(tail, "?"); i >= 0 {
+			values, err := url.ParseQuery(tail[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			session.Params = adHocParams(values)
+		}
+	} else {
+		sessionURL, err := url.Parse(uri)
+		if err != nil {
+			return nil, err
+		}
+
+		if (sessionURL.Scheme == "tcp" || sessionURL.Scheme == srvNet) && len(strings.Trim(sessionURL.Path, "/")) > 0 {
+			return nil, errorAdHocDatabase
+		}
+
+		if sessionURL.User != nil {
+			session.User = sessionURL.User.Username()
+			session.Password, _ = sessionURL.User.Password()
+			sessionURL.User = nil
+		}
+
+		session.Params = adHocParams(sessionURL.Query())
+		sessionURL.RawQuery = ""
+
+		session.Uri = sessionURL.String()
+	}
+
+	// Parameters are checked by the driver now, so a mistyped one like tls=ture does not connect without TLS at export.
+	if _, err := applyParams(mysql.NewConfig(), session.Params); err != nil {
+		return nil, err
+	}
+
+	if _, err := checkURI(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// adHocParams returns parameters of an ad hoc URI as DSN parameters, nil if there are none.
+func adHocParams(values url.Values) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	params := make(map[string]string, len(values))
+	for name := range values {
+		params[name] = values.Get(name)
+	}
+
+	return params
+}
+
+// adHocSession returns a cached session parsed from a given URI, the session is parsed and cached on the first use.
+// The least recently used session is evicted if the cache is full.
+func (c *connManager) adHocSession(uri string) (*Session, error) {
+	k := newAdHocKey(uri)
+
+	c.RLock()
+	s, ok := c.adHoc[k]
+	c.RUnlock()
+
+	if ok {
+		s.touch()
+		return s.session, nil
+	}
+
+	session, err := parseAdHocSession(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if s, ok := c.adHoc[k]; ok {
+		s.touch()
+		return s.session, nil
+	}
+
+	if len(c.adHoc) >= c.maxAdHoc {
+		var oldest adHocKey
+		var oldestAccess time.Time
+		for u, s := range c.adHoc {
+			if access := s.lastTimeAccess(); oldestAccess.IsZero() || access.Before(oldestAccess) {
+				oldest, oldestAccess = u, access
+			}
+		}
+		delete(c.adHoc, oldest)
+	}
+
+	s = &adHocSession{session: session}
+	s.touch()
+	c.adHoc[k] = s
+
+	return session, nil
+}
+
+// evictAdHocSessions removes sessions which have not been used within the keepalive interval.
+func (c *connManager) evictAdHocSessions() {
+	c.Lock()
+	defer c.Unlock()
+
+	for k, s := range c.adHoc {
+		if time.Since(s.lastTimeAccess()) > c.keepAlive {
+			delete(c.adHoc, k)
+		}
+	}
+}
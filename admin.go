@@ -0,0 +1,146 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// States of the classic and admin ports of a server reported by mysql.admin.health.
+const (
+	adminBothReachable = iota
+	adminOnlyReachable
+	classicOnlyReachable
+	adminNoneReachable
+)
+
+// adminAddress returns the address of the admin port of a session.
+// A bare port is completed with the host of the session URI.
+func adminAddress(s *Session) (string, error) {
+	if len(s.AdminPort) == 0 {
+		return "", errorAdminNoPort
+	}
+
+	if _, err := strconv.ParseUint(s.AdminPort, 10, 16); err != nil {
+		if _, _, err = net.SplitHostPort(s.AdminPort); err != nil {
+			return "", errorProbePort
+		}
+		return s.AdminPort, nil
+	}
+
+	sessionURL, err := checkURI(s)
+	if err != nil || sessionURL.Scheme != "tcp" || len(sessionURL.Host) == 0 {
+		return "", errorProbeHost
+	}
+
+	// Each host of a failover list gets the admin port.
+	hosts := strings.Split(sessionURL.Host, ",")
+	for i, h := range hosts {
+		if host, _, err := net.SplitHostPort(h); err == nil {
+			h = host
+		}
+		hosts[i] = net.JoinHostPort(strings.Trim(h, "[]"), s.AdminPort)
+	}
+
+	return strings.Join(hosts, ","), nil
+}
+
+// adminNetwork returns the driver network of the admin port. Connections of a session using an SSH tunnel
+// or a proxy go through it, a failover list is dialed like the classic port and other sessions use a plain TCP
+// connection since the admin port is a TCP port even if the classic one is a socket or a pipe.
+func adminNetwork(mysqlConf *mysql.Config, addr string) string {
+	switch mysqlConf.Net {
+	case "tcp", "unix", pipeNet, srvNet, failoverNet:
+		if strings.Contains(addr, ",") {
+			return failoverNet
+		}
+		return "tcp"
+	}
+
+	return mysqlConf.Net
+}
+
+// canConnect reports whether a new connection can be established with a given config.
+// Managed connections are not used because they stay open when the server runs out of connections.
+func (p *Plugin) canConnect(mysqlConf *mysql.Config) bool {
+	base, err := mysql.NewConnector(mysqlConf)
+	if err != nil {
+		return false
+	}
+
+	conn := sql.OpenDB(base)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.budget.connect)
+	defer cancel()
+
+	if err = conn.PingContext(ctx); err != nil {
+		p.Debugf("Cannot connect to %s: %s", mysqlConf.Addr, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// checkAdmin connects to the classic and the admin port concurrently and returns their state.
+// Only the admin port being reachable usually means the server has run out of connections.
+func (p *Plugin) checkAdmin(s *Session, mysqlConf *mysql.Config) (result interface{}, err error) {
+	addr, err := adminAddress(s)
+	if err != nil {
+		return nil, err
+	}
+
+	adminConf := mysqlConf.Clone()
+	adminConf.Net = adminNetwork(mysqlConf, addr)
+	adminConf.Addr = addr
+
+	var classic, admin bool
+	var wg sync.WaitGroup
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		classic = p.canConnect(mysqlConf)
+	}()
+	go func() {
+		defer wg.Done()
+		admin = p.canConnect(adminConf)
+	}()
+	wg.Wait()
+
+	state := adminNoneReachable
+	switch {
+	case classic && admin:
+		state = adminBothReachable
+	case admin:
+		state = adminOnlyReachable
+	case classic:
+		state = classicOnlyReachable
+	}
+
+	return int64(state), nil
+}
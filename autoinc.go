@@ -0,0 +1,44 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "context"
+
+// autoIncrementQuery returns the next value of each AUTO_INCREMENT column and the maximum value of its type.
+const autoIncrementQuery = `select table_schema, table_name, column_name, current_value, max_value,
+	round(current_value / max_value, 6) as ratio
+	from (select t.table_schema, t.table_name, c.column_name, t.auto_increment as current_value,
+		(case c.data_type when 'tinyint' then 255 when 'smallint' then 65535 when 'mediumint' then 16777215
+		when 'int' then 4294967295 else 18446744073709551615 end) >> if(c.column_type like '%unsigned%', 0, 1) as max_value
+		from information_schema.tables t join information_schema.columns c
+		on c.table_schema = t.table_schema and c.table_name = t.table_name and c.extra like '%auto_increment%'
+		where t.auto_increment is not null and t.table_schema not in ('information_schema', 'performance_schema', 'mysql', 'sys')
+		and (? = '' or t.table_schema = ?)) a
+	order by ratio desc`
+
+// getAutoIncrement returns usage of AUTO_INCREMENT columns of all databases or of a database given as the fourth parameter.
+func (p *Plugin) getAutoIncrement(ctx context.Context, conn *dbConn, params []string) (interface{}, int, error) {
+	dbName := ""
+	if len(params) > 3 {
+		dbName = params[3]
+	}
+
+	return p.getNumericRows(ctx, conn, autoIncrementQuery, dbName, dbName)
+}
@@ -0,0 +1,96 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "time"
+
+// slowShare is the share of the query phase budget in tenths above which a response is considered slow.
+const slowShare = 5
+
+// maxSuggestedBackoff limits the growth of the suggested interval multiplier of a saturated backend.
+const maxSuggestedBackoff = 8
+
+// backendLoad stores saturation signals of a backend.
+// SuggestedBackoff is a multiplier of update intervals of items polling the backend, it is 1 for a healthy one.
+type backendLoad struct {
+	SlowResponses    uint64 `json:"slow_responses"`
+	Rejected         uint64 `json:"rejected"`
+	SuggestedBackoff int    `json:"suggested_backoff"`
+	Saturated        bool   `json:"saturated"`
+}
+
+// busyError marks an error caused by a saturated backend, the item is expected to be polled less often.
+type busyError struct {
+	err error
+}
+
+func (e busyError) Error() string {
+	return "The server is busy, consider a longer update interval: " + e.err.Error()
+}
+
+// addLoad records a response of a backend. The suggested backoff doubles with each consecutive
+// saturation signal and is reset by a normal response.
+func (s *pluginStats) addLoad(backend string, slow, rejected bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	bl, ok := s.Backends[backend]
+	if !ok {
+		bl = &backendLoad{SuggestedBackoff: 1}
+		s.Backends[backend] = bl
+	}
+
+	if slow {
+		bl.SlowResponses++
+	}
+	if rejected {
+		bl.Rejected++
+	}
+
+	bl.Saturated = slow || rejected
+	if !bl.Saturated {
+		bl.SuggestedBackoff = 1
+	} else if bl.SuggestedBackoff < maxSuggestedBackoff {
+		bl.SuggestedBackoff *= 2
+	}
+}
+
+// isSlow reports whether a query took a substantial share of the query phase budget.
+func (p *Plugin) isSlow(elapsed time.Duration) bool {
+	return elapsed >= p.budget.query*slowShare/10
+}
+
+// toBusy returns busyError for errors of a saturated backend if BusyErrors is enabled.
+func (p *Plugin) toBusy(err error) error {
+	if p.options.BusyErrors == 0 {
+		return err
+	}
+
+	switch err.(type) {
+	case phaseTimeoutError:
+		return busyError{err: err}
+	}
+
+	if err == errorCircuitOpen {
+		return busyError{err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,111 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erNoBinaryLogging is the server error of SHOW BINARY LOGS with binary logging disabled.
+const erNoBinaryLogging = 1381
+
+// binlogSize is the result of mysql.binlog.size.
+type binlogSize struct {
+	Count int   `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+// getBinlogSize returns the number and the total size in bytes of binary logs of the server.
+func getBinlogSize(ctx context.Context, conn *dbConn) (interface{}, int, error) {
+	logs, err := queryContext(ctx, conn, "show binary logs")
+	if err != nil {
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == erNoBinaryLogging {
+			return nil, 0, errorNoBinlog
+		}
+		return nil, 0, err
+	}
+
+	result := binlogSize{Count: len(logs)}
+	for _, l := range logs {
+		size, err := strconv.ParseInt(l["File_size"], 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		result.Size += size
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(logs), nil
+}
+
+// binlogRetentionQuery returns variables of binary logging and their retention,
+// expire_logs_days is removed in MySQL 8.4 and binlog_expire_logs_seconds is missing before MySQL 8.0 and MariaDB 10.6.
+const binlogRetentionQuery = `show global variables
+	where variable_name in ('log_bin', 'sync_binlog', 'binlog_expire_logs_seconds', 'expire_logs_days')`
+
+// getBinlogRetention returns the variables of binary logging with retention_seconds, the effective retention
+// of binary logs in seconds, zero if they are never purged automatically.
+func getBinlogRetention(ctx context.Context, conn *dbConn) (interface{}, int, error) {
+	variables, err := queryVariables(ctx, conn, binlogRetentionQuery)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(variables) == 0 {
+		return nil, 0, errorEmptyResult
+	}
+
+	// binlog_expire_logs_seconds takes precedence if it is set, expire_logs_days is used otherwise.
+	var retention int64
+	if seconds, ok := variables["binlog_expire_logs_seconds"]; ok {
+		if retention, err = strconv.ParseInt(seconds, 10, 64); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if days, ok := variables["expire_logs_days"]; ok && retention == 0 {
+		d, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		retention = int64(d * 86400)
+	}
+
+	result := make(map[string]interface{}, len(variables)+1)
+	for name, value := range variables {
+		result[name] = value
+	}
+	result["retention_seconds"] = retention
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(variables), nil
+}
@@ -0,0 +1,128 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker tracks consecutive connection failures of a backend.
+type circuitBreaker struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// backendID identifies a MySQL backend regardless of credentials and other connection settings.
+func backendID(mysqlConf *mysql.Config) string {
+	return mysqlConf.Net + "(" + mysqlConf.Addr + ")"
+}
+
+// breakerErrors are server errors counted by breakers. Other server errors like a denied access or a failed query
+// are answers of a running server, so one session with a wrong password does not open the breaker for the others.
+var breakerErrors = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR
+	1053: true, // ER_SERVER_SHUTDOWN
+	1129: true, // ER_HOST_IS_BLOCKED
+}
+
+// connectionError returns an error if it means the backend is unreachable and nil if the server has answered.
+func connectionError(err error) error {
+	if e, ok := err.(*mysql.MySQLError); ok && !breakerErrors[e.Number] {
+		return nil
+	}
+
+	return err
+}
+
+// breakerAllow returns an error if the breaker of a backend is open.
+// An open breaker becomes half-open after the breaker timeout and lets exactly one attempt through,
+// other attempts fail until the result of that one is recorded.
+// The caller must hold the connection manager's lock.
+func (c *connManager) breakerAllow(backend string) error {
+	if c.breakerThreshold == 0 {
+		return nil
+	}
+
+	b, ok := c.breakers[backend]
+	if !ok || b.state == breakerClosed {
+		return nil
+	}
+
+	if b.state == breakerHalfOpen {
+		return errorCircuitOpen
+	}
+
+	if time.Since(b.openedAt) < c.breakerTimeout {
+		return errorCircuitOpen
+	}
+
+	b.state = breakerHalfOpen
+	impl.Debugf("Circuit breaker of %s is half-open", backend)
+
+	return nil
+}
+
+// breakerRecord updates the breaker of a backend with the result of a connection attempt.
+// The caller must hold the connection manager's lock.
+func (c *connManager) breakerRecord(backend string, err error) {
+	if c.breakerThreshold == 0 {
+		return
+	}
+
+	err = connectionError(err)
+
+	b, ok := c.breakers[backend]
+	if !ok {
+		if err == nil {
+			return
+		}
+		b = &circuitBreaker{}
+		c.breakers[backend] = b
+	}
+
+	if err == nil {
+		if b.state != breakerClosed {
+			impl.Infof("Circuit breaker of %s is closed", backend)
+		}
+		delete(c.breakers, backend)
+		return
+	}
+
+	b.failures++
+
+	if b.state == breakerHalfOpen || b.failures >= c.breakerThreshold {
+		if b.state != breakerOpen {
+			impl.Warningf("Circuit breaker of %s is open after %d consecutive failures", backend, b.failures)
+		}
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
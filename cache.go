@@ -0,0 +1,102 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// cachedResult is a result of a metric served until it expires.
+type cachedResult struct {
+	value   interface{}
+	expires time.Time
+}
+
+// resultCache stores results of metrics with a configured cache time.
+type resultCache struct {
+	sync.Mutex
+	entries map[string]*cachedResult
+}
+
+var results = resultCache{entries: make(map[string]*cachedResult)}
+
+// resultKey identifies a result by the connection, the metric key and the parameters following the credentials.
+func resultKey(mysqlConf *mysql.Config, initStatements []string, key string, params []string) string {
+	if len(params) > 3 {
+		params = params[3:]
+	} else {
+		params = nil
+	}
+
+	return connKey(mysqlConf, initStatements) + "\x00" + key + "\x00" + strings.Join(params, "\x00")
+}
+
+// get returns a result if it has not expired yet.
+func (c *resultCache) get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if r, ok := c.entries[key]; ok && time.Now().Before(r.expires) {
+		return r.value, true
+	}
+
+	return nil, false
+}
+
+// put stores a result for a given time.
+func (c *resultCache) put(key string, value interface{}, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries[key] = &cachedResult{value: value, expires: time.Now().Add(ttl)}
+}
+
+// evict removes expired results.
+func (c *resultCache) evict() {
+	c.Lock()
+	defer c.Unlock()
+
+	for key, r := range c.entries {
+		if time.Now().After(r.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// clear removes all results, e.g. when the configuration is reloaded.
+func (c *resultCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = make(map[string]*cachedResult)
+}
+
+// checkCacheRule checks that a cache rule refers to a metric querying the server.
+func checkCacheRule(r *CacheRule) error {
+	if k, ok := keys[r.Key]; !ok || k.internal {
+		return errorCacheKey
+	}
+
+	return nil
+}
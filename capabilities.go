@@ -0,0 +1,218 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"strings"
+)
+
+// capability is a set of optional server features metrics may depend on.
+type capability int
+
+const (
+	capReplica capability = 1 << iota
+	capPerformanceSchema
+	capRoles
+	capSysSchema
+	capGalera
+	capGTIDSets
+	capBinlog
+	capGroupReplication
+	capClusterMetadata
+)
+
+// Server flavors.
+const (
+	flavorMySQL   = "mysql"
+	flavorMariaDB = "mariadb"
+	flavorPercona = "percona"
+	flavorAurora  = "aurora"
+	flavorTiDB    = "tidb"
+)
+
+// serverInfo describes a server detected through a connection.
+type serverInfo struct {
+	flavor  string
+	version string
+	number  int // number is the comparable form of the version, see versionNumber
+	caps    capability
+}
+
+// has returns true if the server has all given capabilities.
+func (s *serverInfo) has(caps capability) bool {
+	return s.caps&caps == caps
+}
+
+// detectFlavor returns the flavor of a server by its version and version comment.
+// Aurora reports the version of MySQL it is compatible with, so it is detected by serverFlavor only.
+func detectFlavor(version, comment string) string {
+	switch {
+	case strings.Contains(strings.ToLower(version), "mariadb"):
+		return flavorMariaDB
+	case strings.Contains(strings.ToLower(version), "tidb"):
+		return flavorTiDB
+	case strings.Contains(strings.ToLower(comment), "percona"):
+		return flavorPercona
+	}
+
+	return flavorMySQL
+}
+
+// serverFlavor returns the flavor of a server, MySQL compatible servers are checked for the aurora_version variable.
+func serverFlavor(ctx context.Context, conn *dbConn, version, comment string) (string, error) {
+	flavor := detectFlavor(version, comment)
+	if flavor != flavorMySQL {
+		return flavor, nil
+	}
+
+	aurora, err := queryVariables(ctx, conn, "show global variables like 'aurora\\_version'")
+	if err != nil {
+		return "", err
+	}
+
+	if len(aurora) > 0 {
+		return flavorAurora, nil
+	}
+
+	return flavor, nil
+}
+
+// getFlavor returns the flavor of a server.
+func getFlavor(ctx context.Context, conn *dbConn) (interface{}, error) {
+	var version, comment string
+
+	row := conn.connection.QueryRowContext(ctx, "select version(), @@version_comment")
+	if err := row.Scan(&version, &comment); err != nil {
+		return nil, err
+	}
+
+	return serverFlavor(ctx, conn, version, comment)
+}
+
+// detectServer queries the flavor and version of a server.
+func detectServer(ctx context.Context, conn *dbConn) (*serverInfo, error) {
+	var version, comment string
+
+	row := conn.connection.QueryRowContext(ctx, "select version(), @@version_comment")
+	if err := row.Scan(&version, &comment); err != nil {
+		return nil, err
+	}
+
+	// Variables may be hidden from the user, the server is known by its version then.
+	flavor, err := serverFlavor(ctx, conn, version, comment)
+	if err != nil {
+		flavor = detectFlavor(version, comment)
+	}
+
+	return &serverInfo{flavor: flavor, version: version, number: versionNumber(parseVersion(version))}, nil
+}
+
+// capabilityProbes are queries of a single number detecting optional capabilities, which are present
+// if the number is positive.
+var capabilityProbes = []struct {
+	caps  capability
+	query string
+}{
+	{capPerformanceSchema, "select @@performance_schema"},
+	{capRoles, `select count(*) from information_schema.tables
+		where table_schema = 'mysql' and table_name = 'role_edges'`},
+	{capSysSchema, "select count(*) from information_schema.schemata where schema_name = 'sys'"},
+	{capClusterMetadata, `select count(*) from information_schema.schemata
+		where schema_name = 'mysql_innodb_cluster_metadata'`},
+	{capGalera, "select @@global.wsrep_on"},
+	{capBinlog, "select @@global.log_bin"},
+	{capGroupReplication, `select count(*) from information_schema.plugins
+		where plugin_name = 'group_replication' and plugin_status = 'ACTIVE'`},
+}
+
+// probeCapabilities adds the capabilities of a server to its info. A failed probe, e.g. of an unknown variable
+// or without a privilege, means the capability is absent. Only an expired context fails, so that capabilities
+// are not taken as absent for good.
+func probeCapabilities(ctx context.Context, conn *dbConn, info *serverInfo) error {
+	// GTID set functions are available since MySQL 5.6.5, MariaDB has GTIDs of another format.
+	if flavorFamily(info.flavor) == familyMySQL && info.number >= 50605 {
+		info.caps |= capGTIDSets
+	}
+
+	for _, probe := range capabilityProbes {
+		var value int
+		if err := conn.connection.QueryRowContext(ctx, probe.query).Scan(&value); err == nil && value > 0 {
+			info.caps |= probe.caps
+		}
+	}
+
+	replicaStatus := key{query: "show slave status", variants: replicaDiscoveryVariants}
+
+	if replicas, err := queryContext(ctx, conn, replicaStatus.queryFor(info)); err == nil && len(replicas) > 0 {
+		info.caps |= capReplica
+	}
+
+	return ctx.Err()
+}
+
+// serverVersion returns the flavor and version of the server of a connection, they are detected once per managed
+// connection. Failed detections are repeated on the next call.
+func (c *dbConn) serverVersion(ctx context.Context) (*serverInfo, error) {
+	if c.base != nil {
+		return c.base.serverVersion(ctx)
+	}
+
+	c.serverMutex.Lock()
+	defer c.serverMutex.Unlock()
+
+	if c.server == nil {
+		info, err := detectServer(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		c.server = info
+	}
+
+	return c.server, nil
+}
+
+// serverInfo returns the server of a connection with its capabilities, which are probed once per managed connection
+// on the first call.
+func (c *dbConn) serverInfo(ctx context.Context) (*serverInfo, error) {
+	if c.base != nil {
+		return c.base.serverInfo(ctx)
+	}
+
+	server, err := c.serverVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.serverMutex.Lock()
+	defer c.serverMutex.Unlock()
+
+	if !c.probed {
+		// The info is copied as callers of serverVersion may hold the previous one.
+		info := *server
+		if err = probeCapabilities(ctx, c, &info); err != nil {
+			return nil, err
+		}
+		c.server = &info
+		c.probed = true
+	}
+
+	return c.server, nil
+}
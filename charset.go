@@ -0,0 +1,56 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// charsetInfo is the default character set and collation of the server and of each database.
+type charsetInfo struct {
+	Server    map[string]string   `json:"server"`
+	Databases []map[string]string `json:"databases"`
+}
+
+// getCharsets returns default character sets and collations of the server and of all databases.
+func getCharsets(ctx context.Context, conn *dbConn) (interface{}, int, error) {
+	server, err := queryContext(ctx, conn, "select @@character_set_server as character_set, @@collation_server as collation")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(server) == 0 {
+		return nil, 0, errorEmptyResult
+	}
+
+	databases, err := queryContext(ctx, conn, `select schema_name as name, default_character_set_name as character_set,
+		default_collation_name as collation from information_schema.schemata order by schema_name`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jsonData, err := json.Marshal(charsetInfo{Server: server[0], Databases: databases})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(databases), nil
+}
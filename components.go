@@ -0,0 +1,59 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// erNoSuchTable is the server error of a missing table.
+const erNoSuchTable = 1146
+
+// getPluginsList returns installed plugins with their status and components of MySQL 8.0 in JSON format.
+// Components are empty on servers without the component infrastructure.
+func getPluginsList(ctx context.Context, conn *dbConn) (result interface{}, rows int, err error) {
+	plugins, err := queryContext(ctx, conn, `select plugin_name as name, plugin_status as status, plugin_type as type,
+		coalesce(plugin_library, '') as library, plugin_version as version, load_option
+		from information_schema.plugins order by plugin_name`)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	components, err := queryContext(ctx, conn, "select component_id, component_urn from mysql.component order by component_id")
+	if err != nil {
+		if e, ok := err.(*mysql.MySQLError); !ok || e.Number != erNoSuchTable {
+			return nil, 0, err
+		}
+		components = make([]map[string]string, 0)
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"plugins":    plugins,
+		"components": components,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(plugins) + len(components), nil
+}
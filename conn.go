@@ -20,84 +20,311 @@
 package mysql
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
+// querier runs queries on the pool of a managed connection or on a physical connection pinned for an export.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 type dbConn struct {
-	connection     *sql.DB
-	lastTimeAccess time.Time
+	// connID is the server-side id of the last physical connection made, connects is the number of physical connections
+	// made and lastAccess is the last time the connection was used in nanoseconds. They are accessed atomically.
+	connID         uint64
+	connects       uint64
+	lastAccess     int64
+	num            uint64
+	hash           string
+	connection     querier
+	db             *sql.DB
+	base           *dbConn // base is the managed connection of a pinned one, nil for a managed connection
+	created        time.Time
+	session        string
+	addr           string
+	user           string
+	initStatements []string
+
+	// server is the flavor and version of the server detected on the first use, its capabilities are probed
+	// and probed is set on the first use needing them.
+	serverMutex sync.Mutex
+	server      *serverInfo
+	probed      bool
 }
 
 type dsn = string
 
+// connCounter numbers managed connections in the order of creation. It is accessed atomically.
+var connCounter uint64
+
+// connSettings stores settings of the connection manager which can be changed while it is running.
+type connSettings struct {
+	keepAlive    time.Duration
+	timeout      time.Duration
+	maxAdHoc     int
+	failureCache time.Duration
+
+	breakerThreshold int
+	breakerTimeout   time.Duration
+}
+
+// pendingConn is a connection being established, concurrent requests for the same key wait for it.
+type pendingConn struct {
+	done chan struct{}
+	conn *dbConn
+	err  error
+}
+
 // Thread-safe structure for manage connections.
+// A single lock protects the whole state. Connections are established and pinged outside of it,
+// so exports to different servers don't block each other.
 type connManager struct {
-	sync.Mutex
-	connMutex   sync.Mutex
+	sync.RWMutex
+	connSettings
 	connections map[dsn]*dbConn
-	keepAlive   time.Duration
-	timeout     time.Duration
+	pending     map[dsn]*pendingConn
+	adHoc       map[adHocKey]*adHocSession
+	failures    map[dsn]*connFailure
+	breakers    map[string]*circuitBreaker
 }
 
 // updateAccessTime updates the last time a connection was accessed.
 func (r *dbConn) updateAccessTime() {
-	r.lastTimeAccess = time.Now()
+	atomic.StoreInt64(&r.lastAccess, time.Now().UnixNano())
+}
+
+// lastTimeAccess returns the last time a connection was accessed.
+func (r *dbConn) lastTimeAccess() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&r.lastAccess))
+}
+
+// id returns the server-side id of the current physical connection.
+func (r *dbConn) id() uint64 {
+	return atomic.LoadUint64(&r.connID)
+}
+
+// pin returns a connection running its queries on a single physical connection taken from the pool
+// and the server-side id of that physical connection. release returns the physical connection to the pool.
+func (r *dbConn) pin(ctx context.Context) (pinned *dbConn, connID uint64, release func(), err error) {
+	c, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	if err = c.QueryRowContext(ctx, "select connection_id()").Scan(&connID); err != nil {
+		c.Close()
+		return nil, 0, nil, err
+	}
+
+	pinned = &dbConn{
+		connID:         connID,
+		lastAccess:     atomic.LoadInt64(&r.lastAccess),
+		num:            r.num,
+		hash:           r.hash,
+		connection:     c,
+		db:             r.db,
+		base:           r,
+		created:        r.created,
+		session:        r.session,
+		addr:           r.addr,
+		user:           r.user,
+		initStatements: r.initStatements,
+	}
+
+	return pinned, connID, func() { c.Close() }, nil
+}
+
+// label identifies a managed connection in the log.
+func (r *dbConn) label() string {
+	return fmt.Sprintf("#%d %s (%s)", r.num, r.hash, r.addr)
+}
+
+// logConn logs a lifecycle event of a managed connection at the debug level. No secrets are logged.
+// The duration is the time to establish the connection or its age when it is closed.
+func logConn(event string, conn *dbConn, duration time.Duration) {
+	session := conn.session
+	if len(session) == 0 {
+		session = "-"
+	}
+
+	impl.Debugf("%s: number %d, hash %s, session %s, address %s, user %s, connection id %d, duration %s",
+		event, conn.num, conn.hash, session, conn.addr, conn.user, conn.id(), duration)
+}
+
+// connKey returns a key of a managed connection made with a given config and init statements.
+// The key is a hash, so the password is not kept in plain text outside of the driver's config.
+func connKey(mysqlConf *mysql.Config, initStatements []string) dsn {
+	sum := sha256.Sum256([]byte(mysqlConf.FormatDSN() + "\x00" + strings.Join(initStatements, "\x00")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// connHash returns a hash of the connection settings except the password.
+// Unlike the connection number it stays the same across restarts of the agent.
+func connHash(mysqlConf *mysql.Config, initStatements []string) string {
+	conf := mysqlConf.Clone()
+	conf.Passwd = ""
+
+	return connKey(conf, initStatements)[:12]
+}
+
+// newDialer returns a dialer used by the custom networks registered in the driver.
+func newDialer() *net.Dialer {
+	return &net.Dialer{Timeout: dialTimeout()}
+}
+
+// tunnelDials are the dial functions of the SSH tunnels and proxies, kept to dial probes the same way as the driver.
+var tunnelDials = struct {
+	sync.RWMutex
+	dials map[string]mysql.DialContextFunc
+}{dials: make(map[string]mysql.DialContextFunc)}
+
+// registerDial registers a network of a tunnel or a proxy in the driver.
+func registerDial(network string, dial mysql.DialContextFunc) {
+	tunnelDials.Lock()
+	defer tunnelDials.Unlock()
+
+	tunnelDials.dials[network] = dial
+	mysql.RegisterDialContext(network, dial)
+}
+
+// lookupDial returns the dial function of a network registered by registerDial.
+func lookupDial(network string) (mysql.DialContextFunc, bool) {
+	tunnelDials.RLock()
+	defer tunnelDials.RUnlock()
+
+	dial, ok := tunnelDials.dials[network]
+
+	return dial, ok
 }
 
 // NewConnManager initializes connManager structure and runs Go Routine that watches for unused connections.
-func newConnManager(keepAlive, timeout time.Duration) *connManager {
+func newConnManager(settings connSettings) *connManager {
 	connMgr := &connManager{
-		connections: make(map[dsn]*dbConn),
-		keepAlive:   keepAlive,
-		timeout:     timeout,
+		connSettings: settings,
+		connections:  make(map[dsn]*dbConn),
+		pending:      make(map[dsn]*pendingConn),
+		adHoc:        make(map[adHocKey]*adHocSession),
+		failures:     make(map[dsn]*connFailure),
+		breakers:     make(map[string]*circuitBreaker),
 	}
 
 	return connMgr
 }
 
-// create creates a new connection with a given URI and password.
-func (c *connManager) create(mysqlConf *mysql.Config) (*dbConn, error) {
+// dial establishes a new connection with a given config. It is called without the lock.
+func (c *connManager) dial(mysqlConf *mysql.Config, initStatements []string, maxLifetime time.Duration, session string) (*dbConn, error) {
+	base, err := mysql.NewConnector(mysqlConf)
+	if err != nil {
+		return nil, err
+	}
+
+	dbc := &dbConn{
+		num:            atomic.AddUint64(&connCounter, 1),
+		hash:           connHash(mysqlConf, initStatements),
+		created:        time.Now(),
+		session:        session,
+		addr:           mysqlConf.Addr,
+		user:           mysqlConf.User,
+		initStatements: initStatements,
+	}
+	dbc.updateAccessTime()
+	conn := sql.OpenDB(&connector{Connector: base, onConnect: dbc.runConnectHooks})
+
+	conn.SetConnMaxLifetime(maxLifetime)
+	dbc.connection = conn
+	dbc.db = conn
+
+	if err = c.ping(conn); err != nil {
+		conn.Close()
+		dbc.notifyError(err)
+		return nil, err
+	}
+
+	return dbc, nil
+}
+
+// create returns a connection with a given key establishing it if it does not exist yet.
+// Concurrent requests for the same key wait for a single connection attempt.
+func (c *connManager) create(ctx context.Context, dsn dsn, mysqlConf *mysql.Config, initStatements []string, maxLifetime time.Duration, session string) (*dbConn, error) {
+	backend := backendID(mysqlConf)
 
-	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+	c.Lock()
 
-	dsn := mysqlConf.FormatDSN()
+	if conn, ok := c.connections[dsn]; ok {
+		c.Unlock()
+		conn.updateAccessTime()
+		return conn, nil
+	}
 
-	if _, ok := c.connections[dsn]; ok {
-		// Should never happen.
-		panic("connection already exists")
+	if p, ok := c.pending[dsn]; ok {
+		c.Unlock()
+		<-p.done
+		return p.conn, p.err
 	}
 
-	conn, err := sql.Open("mysql", dsn)
+	// The failure cache is checked first, so a half-open breaker lets its only attempt through to a dial.
+	err := c.cachedFailure(dsn)
+	if err == nil {
+		err = c.breakerAllow(backend)
+	}
 	if err != nil {
+		c.Unlock()
 		return nil, err
 	}
 
-	if err = conn.Ping(); err != nil {
-		return nil, err
+	p := &pendingConn{done: make(chan struct{})}
+	c.pending[dsn] = p
+	c.Unlock()
+
+	start := time.Now()
+	_, connectSpan := startSpan(ctx, "connect")
+	p.conn, p.err = c.dial(mysqlConf, initStatements, maxLifetime, session)
+	connectSpan.end(p.err)
+
+	c.Lock()
+	delete(c.pending, dsn)
+	if p.err != nil {
+		c.addFailure(dsn, p.err)
+	} else {
+		delete(c.failures, dsn)
+		c.connections[dsn] = p.conn
 	}
+	c.breakerRecord(backend, p.err)
+	c.Unlock()
+
+	close(p.done)
 
-	c.connections[dsn] = &dbConn{
-		connection:     conn,
-		lastTimeAccess: time.Now(),
+	if p.err != nil {
+		return nil, p.err
 	}
-	impl.Debugf("Created new connection: %s", mysqlConf.Addr)
 
-	return c.connections[dsn], nil
+	stats.addConnection()
+	logConn("Created new connection", p.conn, time.Since(start))
+
+	return p.conn, nil
 }
 
 // get returns a connection with given cid if it exists and also updates lastTimeAccess, otherwise returns nil.
-func (c *connManager) get(mysqlConf *mysql.Config) (conn *dbConn, err error) {
+func (c *connManager) get(dsn dsn) (conn *dbConn, err error) {
 
-	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+	c.RLock()
+	defer c.RUnlock()
 
-	if conn, ok := c.connections[mysqlConf.FormatDSN()]; ok {
+	if conn, ok := c.connections[dsn]; ok {
 		conn.updateAccessTime()
 		return conn, nil
 	}
@@ -105,34 +332,56 @@ func (c *connManager) get(mysqlConf *mysql.Config) (conn *dbConn, err error) {
 	return nil, errorConnectionNotFound
 }
 
-func (c *connManager) closeAllConn() (err error) {
-	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+// closeAll closes all connections concurrently and waits for them at most a given time.
+// Connections still closing after the deadline are released in the background.
+func (c *connManager) closeAll(timeout time.Duration) {
+	c.Lock()
+	conns := c.connections
+	c.connections = make(map[dsn]*dbConn)
+	c.Unlock()
 
-	for dsn, conn := range c.connections {
-		if err = conn.connection.Close(); err == nil {
-			delete(c.connections, dsn)
-			host, _ := mysql.ParseDSN(dsn)
-			impl.Debugf("Closed the connection: %s", host.Addr)
-		}
+	var wg sync.WaitGroup
+	pending := int32(len(conns))
+
+	for _, conn := range conns {
+		wg.Add(1)
+
+		go func(conn *dbConn) {
+			defer wg.Done()
+			defer atomic.AddInt32(&pending, -1)
+
+			if err := conn.close(); err != nil {
+				impl.Debugf("Cannot close the connection %s: %s", conn.label(), err.Error())
+				return
+			}
+			logConn("Closed the connection", conn, time.Since(conn.created))
+		}(conn)
 	}
 
-	// Return the last error only.
-	return
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		impl.Warningf("%d connections are still being closed after %s", atomic.LoadInt32(&pending), timeout)
+	}
 }
 
 // CloseUnused closes each connection that has not been accessed at least within the keepalive interval.
 func (c *connManager) closeUnused() (err error) {
 
-	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+	c.Lock()
+	defer c.Unlock()
 
 	for dsn, conn := range c.connections {
-		if time.Since(conn.lastTimeAccess) > c.keepAlive {
-			if err = conn.connection.Close(); err == nil {
+		if time.Since(conn.lastTimeAccess()) > c.keepAlive {
+			if err = conn.close(); err == nil {
 				delete(c.connections, dsn)
-				host, _ := mysql.ParseDSN(dsn)
-				impl.Debugf("Closed the unused connection: %s", host.Addr)
+				logConn("Closed the unused connection", conn, time.Since(conn.created))
 			}
 		}
 	}
@@ -141,44 +390,128 @@ func (c *connManager) closeUnused() (err error) {
 	return
 }
 
-func (c *connManager) delete(mysqlConf *mysql.Config) (err error) {
-
-	c.connMutex.Lock()
-	defer c.connMutex.Unlock()
+func (c *connManager) delete(dsn dsn) (err error) {
 
-	dsn := mysqlConf.FormatDSN()
+	c.Lock()
+	defer c.Unlock()
 
 	if conn, ok := c.connections[dsn]; ok {
-		if err = conn.connection.Close(); err == nil {
+		if err = conn.close(); err == nil {
 			delete(c.connections, dsn)
-			host, _ := mysql.ParseDSN(dsn)
-			impl.Debugf("Closed the killed connection: %s", host.Addr)
+			logConn("Closed the broken connection", conn, time.Since(conn.created))
 		}
 	}
 
 	return
 }
 
-// GetConnection returns an existing connection or creates a new one.
-func (c *connManager) GetConnection(mysqlConf *mysql.Config) (conn *dbConn, err error) {
+// closeStale closes connections with given keys.
+func (c *connManager) closeStale(keys []dsn) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, dsn := range keys {
+		if conn, ok := c.connections[dsn]; ok {
+			if err := conn.close(); err == nil {
+				delete(c.connections, dsn)
+				logConn("Closed the connection of a changed session", conn, time.Since(conn.created))
+			}
+		}
+	}
+}
 
+// reconfigure applies new settings to the running connection manager.
+func (c *connManager) reconfigure(settings connSettings) {
 	c.Lock()
 	defer c.Unlock()
 
-	conn, err = c.get(mysqlConf)
+	c.connSettings = settings
+}
 
-	if err != nil {
-		conn, err = c.create(mysqlConf)
-	} else {
-		if err = conn.connection.Ping(); err != nil {
-			if strings.Contains(err.Error(), "Connection was killed") {
-				if c.delete(mysqlConf) == nil {
-					err = errorConnectionKilled
-				}
-			}
-			return nil, err
+// ping checks a connection within the connect phase budget. It must be called without the lock.
+func (c *connManager) ping(conn *sql.DB) error {
+	c.RLock()
+	timeout := c.timeout
+	c.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return checkPhase(ctx, conn.PingContext(ctx), "connect", timeout)
+}
+
+// recordResult updates the breaker of a backend with the result of a ping of an existing connection.
+// The write lock is only taken if the backend has a breaker or the ping has failed.
+func (c *connManager) recordResult(backend string, err error) {
+	c.RLock()
+	_, tracked := c.breakers[backend]
+	c.RUnlock()
+
+	if connectionError(err) == nil && !tracked {
+		return
+	}
+
+	c.Lock()
+	c.breakerRecord(backend, err)
+	c.Unlock()
+}
+
+// GetConnection returns an existing connection or creates a new one.
+// Init statements are run on each new physical connection. The physical connection is recycled before it reaches
+// the maximum lifetime unless it is zero. The session name is used for the introspection only.
+func (c *connManager) GetConnection(ctx context.Context, mysqlConf *mysql.Config, initStatements []string, maxLifetime time.Duration, session string) (conn *dbConn, err error) {
+	dsn := connKey(mysqlConf, initStatements)
+
+	if conn, err = c.get(dsn); err != nil {
+		return c.create(ctx, dsn, mysqlConf, initStatements, maxLifetime, session)
+	}
+
+	backend := backendID(mysqlConf)
+
+	_, pingSpan := startSpan(ctx, "ping")
+	err = c.ping(conn.db)
+	pingSpan.end(err)
+
+	if err == nil {
+		c.recordResult(backend, nil)
+		return conn, nil
+	}
+
+	conn.notifyError(err)
+
+	if strings.Contains(err.Error(), "Connection was killed") {
+		c.recordResult(backend, err)
+		if c.delete(dsn) == nil {
+			err = errorConnectionKilled
 		}
+		return nil, err
 	}
 
-	return
+	// The host name may resolve to another address after a failover, so the connection
+	// is dialed again from scratch rather than reusing the pool of the old one.
+	impl.Debugf("Reconnecting the broken connection %s: %s", conn.label(), err.Error())
+	if err = c.delete(dsn); err != nil {
+		c.recordResult(backend, err)
+		return nil, err
+	}
+
+	return c.create(ctx, dsn, mysqlConf, initStatements, maxLifetime, session)
+}
+
+// killQuery kills a query running on the server in the physical connection with a given id.
+// The statement is sent through another physical connection of the pool, since the pinned one is busy with the query.
+func (c *connManager) killQuery(conn *dbConn, connID uint64) {
+	c.RLock()
+	timeout := c.timeout
+	c.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if _, err := conn.db.ExecContext(ctx, fmt.Sprintf("kill query %d", connID)); err != nil {
+		impl.Debugf("Cannot kill the query of connection %s, connection id %d: %s", conn.label(), connID, err.Error())
+		return
+	}
+
+	impl.Debugf("Killed the timed out query of connection %s, connection id %d", conn.label(), connID)
 }
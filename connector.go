@@ -0,0 +1,79 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"strconv"
+)
+
+// connector wraps the driver's connector to run a callback on each new physical connection.
+type connector struct {
+	driver.Connector
+	onConnect func(ctx context.Context, conn driver.Conn) error
+}
+
+// Connect implements the driver.Connector interface.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.onConnect(ctx, conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// queryUint returns the first column of the first row of a query run on a physical connection as an unsigned number.
+func queryUint(ctx context.Context, conn driver.Conn, query string) (uint64, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return 0, driver.ErrSkip
+	}
+
+	rows, err := queryer.QueryContext(ctx, query, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	if err = rows.Next(values); err != nil {
+		if err == io.EOF {
+			err = errorEmptyResult
+		}
+		return 0, err
+	}
+
+	switch v := values[0].(type) {
+	case int64:
+		return uint64(v), nil
+	case []byte:
+		return strconv.ParseUint(string(v), 10, 64)
+	}
+
+	return 0, errorEmptyResult
+}
@@ -0,0 +1,177 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// ConnInfo describes a managed connection passed to connection hooks.
+type ConnInfo struct {
+	Session string
+	Address string
+	User    string
+}
+
+// ConnHook is notified about events of managed connections.
+type ConnHook interface {
+	// OnConnect is called on each new physical connection, it can run SQL on it. An error fails the connection.
+	OnConnect(ctx context.Context, info ConnInfo, conn driver.Conn) error
+
+	// OnClose is called when a managed connection is closed.
+	OnClose(info ConnInfo)
+
+	// OnError is called when a managed connection cannot be established or fails to respond.
+	OnError(info ConnInfo, err error)
+}
+
+// connHook is a connection hook of the plugin itself.
+type connHook interface {
+	onConnect(ctx context.Context, r *dbConn, conn driver.Conn) error
+	onClose(r *dbConn)
+	onError(r *dbConn, err error)
+}
+
+// connHooks are invoked in order, the hooks of the plugin come first.
+var connHooks = []connHook{connIDHook{}, telemetryHook{}, initHook{}}
+
+// RegisterConnHook adds a hook invoked on events of all managed connections after the hooks of the plugin.
+// It is not thread-safe and must be called from an init function only.
+func RegisterConnHook(hook ConnHook) {
+	connHooks = append(connHooks, extHook{hook})
+}
+
+// runConnectHooks runs the connect hooks on a new physical connection and stops at the first error.
+func (r *dbConn) runConnectHooks(ctx context.Context, conn driver.Conn) error {
+	for _, h := range connHooks {
+		if err := h.onConnect(ctx, r, conn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close closes a managed connection and notifies the hooks.
+func (r *dbConn) close() error {
+	if err := r.db.Close(); err != nil {
+		return err
+	}
+
+	for _, h := range connHooks {
+		h.onClose(r)
+	}
+
+	return nil
+}
+
+// notifyError notifies the hooks about a failure of a managed connection.
+func (r *dbConn) notifyError(err error) {
+	for _, h := range connHooks {
+		h.onError(r, err)
+	}
+}
+
+// connIDHook stores CONNECTION_ID() of each physical connection, it is needed to kill timed out queries.
+type connIDHook struct{}
+
+func (connIDHook) onConnect(ctx context.Context, r *dbConn, conn driver.Conn) error {
+	id, err := queryUint(ctx, conn, "select connection_id()")
+	if err != nil {
+		return err
+	}
+
+	atomic.StoreUint64(&r.connID, id)
+
+	return nil
+}
+
+func (connIDHook) onClose(r *dbConn) {}
+
+func (connIDHook) onError(r *dbConn, err error) {}
+
+// telemetryHook maintains the connection counters of mysql.plugin.stats.
+type telemetryHook struct{}
+
+func (telemetryHook) onConnect(ctx context.Context, r *dbConn, conn driver.Conn) error {
+	if atomic.AddUint64(&r.connects, 1) > 1 {
+		stats.addReconnect()
+	}
+
+	return nil
+}
+
+func (telemetryHook) onClose(r *dbConn) {
+	stats.addClose()
+}
+
+func (telemetryHook) onError(r *dbConn, err error) {
+	stats.addConnError()
+}
+
+// initHook runs the init statements of the session on each physical connection.
+type initHook struct{}
+
+func (initHook) onConnect(ctx context.Context, r *dbConn, conn driver.Conn) error {
+	if len(r.initStatements) == 0 {
+		return nil
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		return driver.ErrSkip
+	}
+
+	for _, stmt := range r.initStatements {
+		if _, err := execer.ExecContext(ctx, stmt, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (initHook) onClose(r *dbConn) {}
+
+func (initHook) onError(r *dbConn, err error) {}
+
+// extHook adapts a hook registered by an extension.
+type extHook struct {
+	ConnHook
+}
+
+func (h extHook) onConnect(ctx context.Context, r *dbConn, conn driver.Conn) error {
+	return h.OnConnect(ctx, r.info(), conn)
+}
+
+func (h extHook) onClose(r *dbConn) {
+	h.OnClose(r.info())
+}
+
+func (h extHook) onError(r *dbConn, err error) {
+	h.OnError(r.info(), err)
+}
+
+// info returns a description of a managed connection for extensions.
+func (r *dbConn) info() ConnInfo {
+	return ConnInfo{Session: r.session, Address: r.addr, User: r.user}
+}
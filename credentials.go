@@ -0,0 +1,77 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+// forKey returns a copy of a session using the secondary credentials bound to a given key.
+// The session itself is returned if no credentials are bound to the key.
+func (s *Session) forKey(key string) *Session {
+	for _, c := range s.Credentials {
+		for _, k := range splitList(c.Keys) {
+			if k == key {
+				session := *s
+				session.User = c.User
+				session.Password = c.Password
+
+				return &session
+			}
+		}
+	}
+
+	return s
+}
+
+// variants returns the session itself and its copies with each set of secondary credentials.
+func (s *Session) variants() []*Session {
+	sessions := []*Session{s}
+
+	for _, c := range s.Credentials {
+		session := *s
+		session.User = c.User
+		session.Password = c.Password
+		sessions = append(sessions, &session)
+	}
+
+	return sessions
+}
+
+// checkCredentials returns an error if secondary credentials are bound to unknown keys
+// or a key is bound to several sets of credentials.
+func checkCredentials(s *Session) error {
+	bound := make(map[string]bool)
+
+	for _, c := range s.Credentials {
+		if len(c.User) == 0 {
+			return errorCredentialsUser
+		}
+
+		for _, k := range splitList(c.Keys) {
+			if _, ok := keys[k]; !ok {
+				return errorCredentialsKey
+			}
+
+			if bound[k] {
+				return errorCredentialsTwice
+			}
+			bound[k] = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "context"
+
+// Modes of mysql.db.size given as the fifth parameter.
+const (
+	sizeTotal     = "total"
+	sizeBreakdown = "breakdown"
+)
+
+// dbSizeBreakdownQuery sums sizes of tables of a database, rows is a reserved word since MySQL 8.0.
+const dbSizeBreakdownQuery = `select coalesce(sum(data_length), 0) as data_length, coalesce(sum(index_length), 0) as index_length,
+	coalesce(sum(data_free), 0) as data_free, coalesce(sum(table_rows), 0) as ` + "`rows`" + `
+	from information_schema.tables where table_schema = ?`
+
+// sizeMode returns the mode of mysql.db.size, the total size by default.
+func sizeMode(params []string) (string, error) {
+	if len(params) < 5 || len(params[4]) == 0 {
+		return sizeTotal, nil
+	}
+
+	if params[4] != sizeTotal && params[4] != sizeBreakdown {
+		return "", errorSizeMode
+	}
+
+	return params[4], nil
+}
+
+// getSizeBreakdown returns the data, index and free space of a database in bytes and its estimated number of rows
+// as a JSON object with numbers.
+func (p *Plugin) getSizeBreakdown(ctx context.Context, conn *dbConn, dbName string) (interface{}, error) {
+	return p.getNumericObject(ctx, conn, dbSizeBreakdownQuery, dbName)
+}
+
+// fragmentationQuery returns free space of tables and its share of the allocated space.
+const fragmentationQuery = `select table_name, data_length, index_length, data_free,
+	round(coalesce(data_free / nullif(data_length + index_length + data_free, 0), 0), 4) as ratio
+	from information_schema.tables where table_schema = ? and table_type = 'BASE TABLE'`
+
+// getFragmentation returns free space of tables of a database as a JSON array with numbers, the most fragmented first.
+// Only a table given as the fifth parameter is returned if there is one.
+func (p *Plugin) getFragmentation(ctx context.Context, conn *dbConn, params []string) (interface{}, int, error) {
+	query := fragmentationQuery
+	args := []interface{}{params[3]}
+	if len(params) > 4 && len(params[4]) > 0 {
+		query += " and table_name = ?"
+		args = append(args, params[4])
+	}
+
+	return p.getNumericRows(ctx, conn, query+" order by data_free desc, table_name", args...)
+}
+
+// partitionDiscoveryQuery lists partitions of tables of a database, subpartitions are merged into their partitions.
+const partitionDiscoveryQuery = "select table_schema as `Schema`, table_name as `Table`, partition_name as `Partition` " +
+	"from information_schema.partitions where table_schema = ? and partition_name is not null " +
+	"group by table_schema, table_name, partition_name order by table_name, min(partition_ordinal_position)"
+
+// partitionSizeQuery sums sizes of a partition and its subpartitions.
+const partitionSizeQuery = `select coalesce(sum(table_rows), 0) as table_rows, coalesce(sum(data_length), 0) as data_length,
+	coalesce(sum(index_length), 0) as index_length, coalesce(sum(data_free), 0) as data_free
+	from information_schema.partitions where table_schema = ? and table_name = ? and partition_name = ?
+	having count(*) > 0`
+
+// getPartitionSize returns the number of rows and the sizes of a partition given by the fourth to sixth parameters.
+func (p *Plugin) getPartitionSize(ctx context.Context, conn *dbConn, params []string) (interface{}, error) {
+	if len(params[3]) == 0 || len(params[4]) == 0 || len(params[5]) == 0 {
+		return nil, errorPartitionMissing
+	}
+
+	result, err := p.getNumericObject(ctx, conn, partitionSizeQuery, params[3], params[4], params[5])
+	if err == errorEmptyResult {
+		return nil, errorNoPartition
+	}
+
+	return result, err
+}
@@ -0,0 +1,79 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "fmt"
+
+// deprecatedKeys maps renamed metric keys to their current names. The old keys are still registered and work
+// as aliases until they are removed.
+var deprecatedKeys = map[string]string{
+	"mysql.replication.get_slave_status": "mysql.replication.get_replica_status",
+}
+
+// deprecatedName is a deprecated option or key used in the configuration or requested by the server.
+type deprecatedName struct {
+	kind    string
+	old     string
+	current string
+}
+
+// applyDeprecatedOptions moves values of deprecated options to their current names and returns the ones used.
+// The current name takes precedence if both are set.
+func applyDeprecatedOptions(opts *PluginOptions) (used []deprecatedName) {
+	for name, s := range opts.Sessions {
+		if len(s.URI) == 0 {
+			continue
+		}
+
+		if len(s.Uri) == 0 {
+			s.Uri = s.URI
+		}
+		s.URI = ""
+
+		used = append(used, deprecatedName{
+			kind:    "option",
+			old:     "Sessions." + name + ".URI",
+			current: "Sessions." + name + ".Uri",
+		})
+	}
+
+	return
+}
+
+// error returns an error reporting the use of a deprecated name.
+func (d deprecatedName) error() error {
+	return zabbixError(fmt.Sprintf("The %s %s is deprecated, use %s instead", d.kind, d.old, d.current))
+}
+
+// useDeprecated counts a use of a deprecated name and logs a warning on the first one.
+// If DeprecationErrors is enabled, an error is returned instead.
+func (p *Plugin) useDeprecated(d deprecatedName) error {
+	count := stats.addDeprecation(d.old)
+
+	if p.options.DeprecationErrors == 1 {
+		return d.error()
+	}
+
+	if count == 1 {
+		p.Warningf("The %s %s is deprecated and will be removed, use %s instead", d.kind, d.old, d.current)
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// innodbStatusLimit is the maximum length of the SHOW ENGINE INNODB STATUS excerpt included in a bundle.
+const innodbStatusLimit = 8192
+
+// diagnosticsQueries are the sections of a bundle, queries with variants are resolved for the server like metrics.
+var diagnosticsQueries = map[string]key{
+	"status": {query: `show global status where Variable_name in (
+		'Uptime', 'Threads_connected', 'Threads_running', 'Max_used_connections', 'Questions', 'Slow_queries',
+		'Aborted_clients', 'Aborted_connects', 'Created_tmp_disk_tables', 'Select_full_join', 'Sort_merge_passes',
+		'Table_locks_waited', 'Opened_tables', 'Innodb_buffer_pool_reads', 'Innodb_buffer_pool_read_requests',
+		'Innodb_buffer_pool_wait_free', 'Innodb_row_lock_waits', 'Innodb_row_lock_time')`},
+	"waits": {query: `select event_name, count_star, sum_timer_wait
+		from performance_schema.events_waits_summary_global_by_event_name
+		where event_name <> 'idle' and count_star > 0
+		order by sum_timer_wait desc limit 10`},
+	"digests": {query: `select schema_name, digest_text, count_star, sum_timer_wait, sum_rows_examined
+		from performance_schema.events_statements_summary_by_digest
+		order by sum_timer_wait desc limit 10`},
+	"replication": {query: "show slave status", variants: replicaStatusVariants},
+	"innodb":      {query: "show engine innodb status"},
+}
+
+// diagnosticsLimiter remembers when a bundle was collected successfully last time for each target
+// and which targets are being collected right now.
+type diagnosticsLimiter struct {
+	sync.Mutex
+	lastRun map[string]time.Time
+	running map[string]bool
+}
+
+var diagLimiter = diagnosticsLimiter{lastRun: make(map[string]time.Time), running: make(map[string]bool)}
+
+// allow returns true and marks the target as being collected if a bundle for the target may be collected now.
+func (l *diagnosticsLimiter) allow(target string, interval time.Duration) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.running[target] {
+		return false
+	}
+
+	if last, ok := l.lastRun[target]; ok && time.Since(last) < interval {
+		return false
+	}
+
+	l.running[target] = true
+
+	return true
+}
+
+// done ends a collection of the target, only a complete one delays the next collection by the interval.
+func (l *diagnosticsLimiter) done(target string, success bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.running, target)
+	if success {
+		l.lastRun[target] = time.Now()
+	}
+}
+
+// getDiagnostics gathers a compact diagnostic bundle as one JSON object.
+// A failure of one section does not fail the entire bundle, it is reported in the "errors" field instead.
+// Sections are queried in the order of their names, so bundles of different runs can be compared.
+func (p *Plugin) getDiagnostics(conn *dbConn, mysqlConf *mysql.Config) (result interface{}, err error) {
+	target := mysqlConf.User + "@" + mysqlConf.Addr
+	if !diagLimiter.allow(target, time.Duration(p.options.DiagnosticsInterval)*time.Second) {
+		return nil, errorDiagnosticsLimited
+	}
+	complete := false
+	defer func() { diagLimiter.done(target, err == nil && complete) }()
+
+	// A read of each query is limited by the query budget of the connection too, see DiagnosticsTimeout.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.options.DiagnosticsTimeout)*time.Second)
+	defer cancel()
+
+	bundle := map[string]interface{}{
+		"collected_at": time.Now().Unix(),
+	}
+	errs := make(map[string]string)
+
+	sections := make([]string, 0, len(diagnosticsQueries))
+	for section := range diagnosticsQueries {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		k := diagnosticsQueries[section]
+		if err := resolveQuery(ctx, conn, &k); err != nil {
+			errs[section] = err.Error()
+			continue
+		}
+
+		data, err := queryContext(ctx, conn, k.query)
+		if err != nil {
+			errs[section] = err.Error()
+			continue
+		}
+
+		switch section {
+		case "status":
+			m := make(map[string]string)
+			for _, row := range data {
+				m[row["Variable_name"]] = row["Value"]
+			}
+			bundle[section] = m
+		case "innodb":
+			if len(data) > 0 {
+				status := data[0]["Status"]
+				if len(status) > innodbStatusLimit {
+					status = status[:innodbStatusLimit]
+				}
+				bundle[section] = status
+			}
+		case "replication":
+			legacyRows(data)
+			bundle[section] = data
+		default:
+			bundle[section] = data
+		}
+	}
+
+	if len(errs) > 0 {
+		bundle["errors"] = errs
+	}
+
+	// A bundle cut short by the timeout is returned, but it does not delay the next collection.
+	complete = ctx.Err() == nil
+
+	jsonData, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
+
+// queryContext runs a query with optional arguments within a given context and returns all rows.
+func queryContext(ctx context.Context, conn *dbConn, query string, args ...interface{}) (data []map[string]string, err error) {
+	err = runQuery(ctx, conn, query, func(rows *sql.Rows) (int, error) {
+		data, err = rows2data(rows)
+		return len(data), err
+	}, args...)
+
+	return data, err
+}
+
+// runQuery runs a query within a span, passes the rows to a scan function and logs the query with the number of rows.
+func runQuery(ctx context.Context, conn *dbConn, query string, scan func(*sql.Rows) (int, error), args ...interface{}) error {
+	start := time.Now()
+	ctx, querySpan := startSpan(ctx, "query", "db.statement", query)
+
+	rows, err := conn.connection.QueryContext(ctx, query, args...)
+	if err != nil {
+		querySpan.end(err)
+		queryLog.record(conn, query, start, 0, err)
+		return err
+	}
+	defer rows.Close()
+
+	n, err := scan(rows)
+	querySpan.end(err)
+	queryLog.record(conn, query, start, n, err)
+
+	return err
+}
@@ -0,0 +1,87 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "context"
+
+// Families of flavors sharing the syntax of MySQL versions.
+const (
+	familyMySQL   = "mysql"
+	familyMariaDB = "mariadb"
+	familyTiDB    = "tidb"
+)
+
+// queryVariant is a query of a metric replacing the default one on servers of a family within a range of versions.
+type queryVariant struct {
+	family string // family of servers, all families if it is empty
+	since  int    // since is the first version using the query like 80022 for 8.0.22, zero for all versions
+	before int    // before is the first version not using the query anymore, zero if there is none
+	query  string
+}
+
+// versionNumber returns a comparable number of a version, e.g. 80022 for 8.0.22.
+func versionNumber(major, minor, patch int) int {
+	return major*10000 + minor*100 + patch
+}
+
+// flavorFamily returns the family of a flavor, Percona and Aurora follow the versions of MySQL.
+func flavorFamily(flavor string) string {
+	switch flavor {
+	case flavorMariaDB:
+		return familyMariaDB
+	case flavorTiDB:
+		return familyTiDB
+	}
+
+	return familyMySQL
+}
+
+// matches checks that a variant is used for a server.
+func (v *queryVariant) matches(family string, version int) bool {
+	return (len(v.family) == 0 || v.family == family) && version >= v.since && (v.before == 0 || version < v.before)
+}
+
+// queryFor returns the query of the first variant matching a server, the default query if there is none.
+func (k *key) queryFor(info *serverInfo) string {
+	family := flavorFamily(info.flavor)
+
+	for i := range k.variants {
+		if k.variants[i].matches(family, info.number) {
+			return k.variants[i].query
+		}
+	}
+
+	return k.query
+}
+
+// resolveQuery replaces the query of a metric with the variant for the server if the metric has variants.
+func resolveQuery(ctx context.Context, conn *dbConn, k *key) error {
+	if len(k.variants) == 0 {
+		return nil
+	}
+
+	info, err := conn.serverVersion(ctx)
+	if err != nil {
+		return err
+	}
+	k.query = k.queryFor(info)
+
+	return nil
+}
@@ -33,7 +33,73 @@ const (
 	errorConnectionNotFound = zabbixError("Active connection is not found")
 	errorConnectionKilled   = zabbixError("Connection was killed")
 	errorUserPassword       = zabbixError("The username and password cannot be used with the session name")
+	errorURICredentials     = zabbixError("The username and password cannot be used with credentials embedded in the URI")
+	errorPatternBoth        = zabbixError("Match and MatchRegexp cannot be used together")
+	errorPatternValue       = zabbixError("A session matched by a pattern must be named by letters, digits, dots, dashes and underscores")
+	errorSecretEnv          = zabbixError("The environment variable of the secret reference is not set")
+	errorSecretLiteral      = zabbixError("Passwords must be given as secret references of PasswordRef")
+	errorSecretRef          = zabbixError("The secret reference must start with env:, file: or vault:")
+	errorSecretBoth         = zabbixError("Password and PasswordRef cannot be given together")
+	errorSecretRemote       = zabbixError("Secret references are resolved only in a file inventory")
+	errorSecretVaultRef     = zabbixError("The Vault secret reference must be given as vault:path#field")
+	errorSecretVaultEnv     = zabbixError("VAULT_ADDR and VAULT_TOKEN must be set to read secrets from Vault")
+	errorSecretVaultStatus  = zabbixError("Vault returned an unexpected HTTP status")
+	errorSecretVaultField   = zabbixError("The field is not found in the Vault secret")
+	errorCredentialsUser    = zabbixError("The user of secondary credentials cannot be empty")
+	errorCredentialsKey     = zabbixError("Secondary credentials are bound to an unknown key")
+	errorCredentialsTwice   = zabbixError("A key cannot be bound to several sets of secondary credentials")
+	errorCircuitOpen        = zabbixError("The circuit breaker of the server is open after consecutive connection failures")
+	errorInventoryURI       = zabbixError("The inventory source must be an http, https or file URL")
+	errorInventoryStatus    = zabbixError("The inventory source returned an unexpected HTTP status")
 	errorNoReplication      = zabbixError("Replication is not configured")
+	errorRelayLogName       = zabbixError("Cannot parse the number of the relay log")
+	errorNoBinlog           = zabbixError("Binary logging is disabled")
+	errorNoGroupMember      = zabbixError("The server is not a member of a replication group")
+	errorNotInCluster       = zabbixError("The server is not registered in the InnoDB Cluster metadata")
+	errorNotGalera          = zabbixError("The server is not a Galera Cluster node")
+	errorSRVNoRecords       = zabbixError("No SRV records found")
+	errorDiagnosticsOff     = zabbixError("The mysql.diagnostics key is disabled by the configuration")
+	errorDiagnosticsLimited = zabbixError("Diagnostics have already been collected recently")
+	errorFilterKey          = zabbixError("The filter key must be a known metric returning JSON")
+	errorFilterRename       = zabbixError("The filter rename list must consist of old:new pairs")
+	errorFilterDuplicate    = zabbixError("Only one filter can be defined per metric key")
+	errorSSHOptions         = zabbixError("SSHUser, SSHKeyFile and SSHKnownHosts are required if SSHHost is set")
+	errorSSHClosed          = zabbixError("The SSH tunnel was closed while it was being established")
+	errorTunnelNetwork      = zabbixError("Only a single tcp host can be reached through an SSH tunnel or a proxy")
+	errorProxyURI           = zabbixError("The proxy must be given as socks5://[user:password@]host:port")
+	errorProxySSH           = zabbixError("The proxy cannot be used together with an SSH tunnel")
+	errorProbeNoPorts       = zabbixError("There are no ports to probe configured for the session")
+	errorProbeHost          = zabbixError("Bare ports to probe require a session URI with the tcp scheme")
+	errorProbePort          = zabbixError("Ports to probe must be given as port or host:port")
+	errorAdminNoPort        = zabbixError("There is no admin port configured for the session")
+	errorQueryLogWindow     = zabbixError("The query log window must be given as a number of seconds")
+	errorTraceEndpoint      = zabbixError("The trace endpoint must be an http or https URL")
+	errorLogOutput          = zabbixError("The server does not log to tables, log_output must include TABLE")
+	errorLogLimit           = zabbixError("The number of log entries must be a positive number")
+	errorLatencyKey         = zabbixError("The parameter must be a key supported by the plugin")
+	errorCacheKey           = zabbixError("The cache rule key must be a known metric querying the server")
+	errorCacheDuplicate     = zabbixError("Only one cache rule can be defined per metric key")
+	errorNetworkPlatform    = zabbixError("The network is not supported on this platform")
+	errorServiceNotLocal    = zabbixError("The service state is available for servers on localhost only")
+	errorServiceWindows     = zabbixError("The service state is available on Windows only")
+	errorPatternInvalid     = zabbixError("Invalid pattern of names")
+	errorUnknownVariable    = zabbixError("The variable does not exist")
+	errorAdHocDatabase      = zabbixError("A database cannot be selected by the URI of a metric")
+	errorVariableMissing    = zabbixError("The variable name must be given as the fourth parameter")
+	errorSourceMissing      = zabbixError("The source must be given as the fourth parameter")
+	errorNullValue          = zabbixError("NullValue must be one of empty, null or omit")
+	errorBinaryEncoding     = zabbixError("BinaryEncoding must be either base64 or hex")
+	errorLLDFormat          = zabbixError("The discovery format must be either array or data")
+	errorMacroKey           = zabbixError("The macro rule key must be a known discovery metric")
+	errorMacroName          = zabbixError("The macro rule names must consist of field:{#MACRO} pairs")
+	errorMacroDuplicate     = zabbixError("Only one macro rule can be defined per metric key")
+	errorSizeMode           = zabbixError("The size mode must be either total or breakdown")
+	errorPartitionMissing   = zabbixError("The database, the table and the partition must be given as the fourth to sixth parameters")
+	errorNoPartition        = zabbixError("The partition does not exist")
+	errorVersionMode        = zabbixError("The version mode must be json if it is given")
+	errorNoHeartbeat        = zabbixError("The heartbeat table has no rows of the source")
+	errorNoHeartbeatTable   = zabbixError("The heartbeat table does not exist")
+	errorEmptyResult        = zabbixError("The query returned no value")
 )
 
 // formatZabbixError formats a given error text. It capitalizes the first letter and adds a dot to the end.
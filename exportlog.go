@@ -0,0 +1,41 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "time"
+
+// exportTrace collects details of an export logged if DebugExports is enabled.
+// The number of rows is negative if no query has been run.
+type exportTrace struct {
+	start   time.Time
+	session string
+	address string
+	rows    int
+}
+
+// logExport logs the key, the resolved session, the number of rows returned and the elapsed time of an export.
+func (p *Plugin) logExport(key string, t *exportTrace, err error) {
+	if err != nil {
+		p.Infof("Export %s: session %s, address %s, elapsed %s, error: %s", key, t.session, t.address, time.Since(t.start), err.Error())
+		return
+	}
+
+	p.Infof("Export %s: session %s, address %s, rows %d, elapsed %s", key, t.session, t.address, t.rows, time.Since(t.start))
+}
@@ -0,0 +1,95 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// failoverNet is the name of the network registered in the driver for URIs listing several hosts.
+const failoverNet = "tcp-failover"
+
+// failoverHosts remembers the last healthy host for each list of hosts.
+type failoverHosts struct {
+	sync.Mutex
+	healthy map[string]string
+}
+
+var failover = failoverHosts{healthy: make(map[string]string)}
+
+// order returns the hosts of a comma separated list starting with the last healthy one.
+func (f *failoverHosts) order(addr string) []string {
+	f.Lock()
+	defer f.Unlock()
+
+	hosts := strings.Split(addr, ",")
+
+	healthy, ok := f.healthy[addr]
+	if !ok {
+		return hosts
+	}
+
+	result := []string{healthy}
+	for _, h := range hosts {
+		if h != healthy {
+			result = append(result, h)
+		}
+	}
+
+	return result
+}
+
+// remember stores a host which has been connected successfully.
+func (f *failoverHosts) remember(addr, host string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.healthy[addr] != host {
+		impl.Debugf("Switched to %s from the list %s", host, addr)
+	}
+
+	f.healthy[addr] = host
+}
+
+// dialFailover tries each host of a comma separated list and returns the first established connection.
+func dialFailover(ctx context.Context, addr string) (conn net.Conn, err error) {
+	dialer := newDialer()
+
+	for _, host := range failover.order(addr) {
+		if conn, err = dialer.DialContext(ctx, "tcp", host); err == nil {
+			failover.remember(addr, host)
+			return conn, nil
+		}
+
+		impl.Debugf("Cannot connect to %s: %s", host, err.Error())
+	}
+
+	// Return the last error only.
+	return nil, err
+}
+
+func init() {
+	mysql.RegisterDialContext(failoverNet, dialFailover)
+}
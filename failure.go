@@ -0,0 +1,73 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "time"
+
+// maxFailureBackoff limits the growth of the failure cache time for consecutive failures.
+const maxFailureBackoff = 8
+
+// connFailure is a cached error of a failed connection attempt.
+type connFailure struct {
+	err     error
+	backoff int
+	until   time.Time
+}
+
+// cachedFailure returns the cached error of the last connection attempt if it has not expired yet.
+// The caller must hold the connection manager's lock.
+func (c *connManager) cachedFailure(dsn dsn) error {
+	if f, ok := c.failures[dsn]; ok && time.Now().Before(f.until) {
+		return f.err
+	}
+
+	return nil
+}
+
+// addFailure caches the error of a failed connection attempt, the cache time doubles with each consecutive failure.
+// The caller must hold the connection manager's lock.
+func (c *connManager) addFailure(dsn dsn, err error) {
+	if c.failureCache == 0 {
+		return
+	}
+
+	f, ok := c.failures[dsn]
+	if !ok {
+		f = &connFailure{backoff: 1}
+		c.failures[dsn] = f
+	} else if f.backoff < maxFailureBackoff {
+		f.backoff *= 2
+	}
+
+	f.err = err
+	f.until = time.Now().Add(time.Duration(f.backoff) * c.failureCache)
+}
+
+// evictFailures removes failures expired long enough to not be considered consecutive anymore.
+func (c *connManager) evictFailures() {
+	c.Lock()
+	defer c.Unlock()
+
+	for dsn, f := range c.failures {
+		if time.Since(f.until) > maxFailureBackoff*c.failureCache {
+			delete(c.failures, dsn)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// fieldFilter is a compiled form of FieldFilter.
+type fieldFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+	rename  map[string]string
+}
+
+// splitList splits a comma separated list and trims spaces around its items.
+func splitList(list string) (result []string) {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			result = append(result, item)
+		}
+	}
+
+	return
+}
+
+// newFieldFilter compiles a field filter defined in the configuration.
+func newFieldFilter(f *FieldFilter) (*fieldFilter, error) {
+	if _, ok := keys[f.Key]; !ok || !keys[f.Key].json {
+		return nil, errorFilterKey
+	}
+
+	filter := &fieldFilter{
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+		rename:  make(map[string]string),
+	}
+
+	for _, field := range splitList(f.Include) {
+		filter.include[field] = true
+	}
+
+	for _, field := range splitList(f.Exclude) {
+		filter.exclude[field] = true
+	}
+
+	for _, pair := range splitList(f.Rename) {
+		names := strings.Split(pair, ":")
+		if len(names) != 2 || len(names[0]) == 0 || len(names[1]) == 0 {
+			return nil, errorFilterRename
+		}
+		filter.rename[names[0]] = names[1]
+	}
+
+	return filter, nil
+}
+
+// field returns the name a field is renamed to and false if the field is filtered out.
+func (f *fieldFilter) field(name string) (string, bool) {
+	if (len(f.include) > 0 && !f.include[name]) || f.exclude[name] {
+		return "", false
+	}
+
+	if newName, ok := f.rename[name]; ok {
+		name = newName
+	}
+
+	return name, true
+}
+
+// apply returns a copy of an object with filtered and renamed fields.
+func (f *fieldFilter) apply(obj map[string]string) map[string]string {
+	result := make(map[string]string)
+
+	for name, value := range obj {
+		if name, ok := f.field(name); ok {
+			result[name] = value
+		}
+	}
+
+	return result
+}
+
+// applyTyped is apply for objects with typed values.
+func (f *fieldFilter) applyTyped(obj map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	for name, value := range obj {
+		if name, ok := f.field(name); ok {
+			result[name] = value
+		}
+	}
+
+	return result
+}
+
+// filterFields applies the filter configured for a given key to an object or to each object of a list.
+func (p *Plugin) filterFields(key string, data interface{}) interface{} {
+	f, ok := p.filters[key]
+	if !ok {
+		return data
+	}
+
+	switch v := data.(type) {
+	case map[string]string:
+		return f.apply(v)
+	case []map[string]string:
+		result := make([]map[string]string, 0, len(v))
+		for _, obj := range v {
+			result = append(result, f.apply(obj))
+		}
+		return result
+	case map[string]interface{}:
+		return f.applyTyped(v)
+	case []map[string]interface{}:
+		result := make([]map[string]interface{}, 0, len(v))
+		for _, obj := range v {
+			result = append(result, f.applyTyped(obj))
+		}
+		return result
+	}
+
+	return data
+}
+
+// compileNamePattern compiles a pattern of variable names given as a metric parameter.
+// The pattern is a LIKE pattern matched case-insensitively, e.g. Innodb_%,
+// or a regular expression enclosed in slashes, e.g. /^Innodb_(rows|data)_/.
+func compileNamePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, errorPatternInvalid
+		}
+		return re, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?i)^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// systemSchemas matches schemas skipped by database discovery unless another exclude pattern is given.
+const systemSchemas = "^(information_schema|performance_schema|mysql|sys)$"
+
+// nameFilter selects objects by their names.
+type nameFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// match checks that a name matches the include pattern, if there is one, and does not match the exclude pattern.
+func (f nameFilter) match(name string) bool {
+	return (f.include == nil || f.include.MatchString(name)) && (f.exclude == nil || !f.exclude.MatchString(name))
+}
+
+// objectFilter compiles regular expressions of discovered objects to include and exclude
+// given as the fifth and sixth parameters. The exclude pattern is used if the sixth parameter is empty.
+func objectFilter(params []string, exclude string) (f nameFilter, err error) {
+	if len(params) > 5 && len(params[5]) > 0 {
+		exclude = params[5]
+	}
+
+	if len(exclude) > 0 {
+		if f.exclude, err = regexp.Compile(exclude); err != nil {
+			return f, errorPatternInvalid
+		}
+	}
+
+	if len(params) > 4 && len(params[4]) > 0 {
+		if f.include, err = regexp.Compile(params[4]); err != nil {
+			return f, errorPatternInvalid
+		}
+	}
+
+	return f, nil
+}
+
+// matchNames returns variables with names selected by a filter.
+func matchNames(variables map[string]string, names nameFilter) map[string]string {
+	if names.include == nil && names.exclude == nil {
+		return variables
+	}
+
+	result := make(map[string]string)
+	for name, value := range variables {
+		if names.match(name) {
+			result[name] = value
+		}
+	}
+
+	return result
+}
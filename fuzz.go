@@ -0,0 +1,75 @@
+//go:build gofuzz
+// +build gofuzz
+
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+// Targets for go-fuzz (github.com/dvyukov/go-fuzz):
+//
+//	go-fuzz-build && go-fuzz -func FuzzURI
+
+import (
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// FuzzURI parses a URI or a DSN given as a metric parameter and checks that the DSN built from it round-trips.
+func FuzzURI(data []byte) int {
+	session, err := parseAdHocSession(string(data))
+	if err != nil {
+		return 0
+	}
+
+	conf, err := impl.getConfigDSN(session)
+	if err != nil {
+		return 0
+	}
+
+	parsed, err := mysql.ParseDSN(conf.FormatDSN())
+	if err != nil {
+		panic(fmt.Sprintf("cannot parse the DSN built from %q: %s", data, err))
+	}
+
+	if parsed.Net != conf.Net || parsed.Addr != conf.Addr || parsed.User != conf.User || parsed.Passwd != conf.Passwd {
+		panic(fmt.Sprintf("the DSN built from %q does not round-trip", data))
+	}
+
+	return 1
+}
+
+// FuzzNormalizeURI checks that normalized URIs are either rejected or have a known scheme.
+func FuzzNormalizeURI(data []byte) int {
+	uri := normalizeURI(string(data))
+
+	sessionURL, err := checkURI(&Session{Uri: uri})
+	if err != nil {
+		return 0
+	}
+
+	switch sessionURL.Scheme {
+	case "tcp", "unix", srvNet, pipeNet:
+	default:
+		panic(fmt.Sprintf("unexpected scheme of %q", uri))
+	}
+
+	return 1
+}
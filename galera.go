@@ -0,0 +1,141 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// galeraPrefix is the prefix of status variables of Galera Cluster, Percona XtraDB Cluster and MariaDB Galera.
+const galeraPrefix = "wsrep_"
+
+const galeraStatusQuery = "show global status like 'wsrep\\_%'"
+
+// galeraScalars maps scalar Galera metrics to their status variables.
+var galeraScalars = map[string]string{
+	"mysql.galera.ready":          "wsrep_ready",
+	"mysql.galera.cluster_status": "wsrep_cluster_status",
+	"mysql.galera.local_state":    "wsrep_local_state_comment",
+	"mysql.galera.cluster_size":   "wsrep_cluster_size",
+	"mysql.galera.primary":        "wsrep_cluster_status",
+}
+
+// galeraFlowVariables are status variables of flow control and replication health returned by mysql.galera.flow.
+var galeraFlowVariables = []string{
+	"wsrep_flow_control_paused",
+	"wsrep_flow_control_paused_ns",
+	"wsrep_flow_control_sent",
+	"wsrep_flow_control_recv",
+	"wsrep_local_send_queue_avg",
+	"wsrep_local_recv_queue_avg",
+	"wsrep_local_cert_failures",
+	"wsrep_local_bf_aborts",
+}
+
+// isGalera checks that a metric is served from the wsrep_% status variables.
+func isGalera(key string) bool {
+	_, ok := galeraScalars[key]
+	return ok || key == "mysql.galera.status" || key == "mysql.galera.flow"
+}
+
+// galeraStatus returns the wsrep_% variables of global status.
+func galeraStatus(status map[string]string) map[string]string {
+	m := make(map[string]string)
+	for name, value := range status {
+		if strings.HasPrefix(strings.ToLower(name), galeraPrefix) {
+			m[name] = value
+		}
+	}
+
+	return m
+}
+
+// galeraResult returns the value of a Galera metric from the wsrep_% status variables,
+// a server without them is not a node of a cluster.
+func galeraResult(key string, status map[string]string) (interface{}, error) {
+	status = galeraStatus(status)
+	if len(status) == 0 {
+		return nil, errorNotGalera
+	}
+
+	if key == "mysql.galera.status" {
+		jsonData, err := json.Marshal(status)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(jsonData), nil
+	}
+
+	if key == "mysql.galera.flow" {
+		flow := make(map[string]string, len(galeraFlowVariables))
+		for _, name := range galeraFlowVariables {
+			if value, err := lookupVariable(status, name); err == nil {
+				flow[name] = value.(string)
+			}
+		}
+
+		jsonData, err := json.Marshal(flow)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(jsonData), nil
+	}
+
+	value, err := lookupVariable(status, galeraScalars[key])
+	if err != nil {
+		return nil, err
+	}
+
+	switch key {
+	case "mysql.galera.ready":
+		// wsrep_ready is ON or OFF.
+		return onOff(value == "ON"), nil
+	case "mysql.galera.primary":
+		// A node out of the primary component has no quorum, e.g. after a split-brain.
+		return onOff(value == "Primary"), nil
+	case "mysql.galera.cluster_size":
+		return convertValue(value.(string), valueInt)
+	}
+
+	return value, nil
+}
+
+// onOff converts a flag to 1 or 0.
+func onOff(on bool) int64 {
+	if on {
+		return 1
+	}
+
+	return 0
+}
+
+// getGalera returns the value of a Galera metric.
+func getGalera(ctx context.Context, conn *dbConn, key string) (interface{}, error) {
+	status, err := queryVariables(ctx, conn, galeraStatusQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	return galeraResult(key, status)
+}
@@ -0,0 +1,99 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// groupMemberQuery returns the state of this member of a replication group. MySQL 5.7 has no member_role,
+// so the role is derived from the primary of a single-primary group, all members are primary in a multi-primary group.
+const groupMemberQuery = `select m.member_id, m.member_host, m.member_port, m.member_state,
+	case (select variable_value from performance_schema.global_status
+		where variable_name = 'group_replication_primary_member')
+		when '' then 'PRIMARY' when m.member_id then 'PRIMARY' else 'SECONDARY' end as member_role,
+	s.count_transactions_in_queue, s.count_transactions_checked, s.count_conflicts_detected,
+	s.count_transactions_rows_validating
+	from performance_schema.replication_group_members m
+	left join performance_schema.replication_group_member_stats s on s.member_id = m.member_id
+	where m.member_id = @@server_uuid`
+
+var groupMemberVariants = []queryVariant{
+	{family: familyMySQL, since: 80002, query: `select m.member_id, m.member_host, m.member_port, m.member_state,
+	m.member_role, s.count_transactions_in_queue, s.count_transactions_checked, s.count_conflicts_detected,
+	s.count_transactions_rows_validating, s.count_transactions_remote_in_applier_queue
+	from performance_schema.replication_group_members m
+	left join performance_schema.replication_group_member_stats s on s.member_id = m.member_id
+	where m.member_id = @@server_uuid`},
+}
+
+// flowControlQuery returns the queues of all members of a replication group, the applier queue is known since MySQL 8.0.2.
+const flowControlQuery = `select member_id, count_transactions_in_queue as certifier_queue, null as applier_queue
+	from performance_schema.replication_group_member_stats order by member_id`
+
+var flowControlVariants = []queryVariant{
+	{family: familyMySQL, since: 80002, query: `select member_id, count_transactions_in_queue as certifier_queue,
+	count_transactions_remote_in_applier_queue as applier_queue
+	from performance_schema.replication_group_member_stats order by member_id`},
+}
+
+// flowControl is the result of mysql.group_replication.flow_control. Throttle counters are status variables
+// of MySQL 8.2 and later, they are empty on earlier versions.
+type flowControl struct {
+	Members   []map[string]string `json:"members"`
+	Variables map[string]string   `json:"variables"`
+	Throttle  map[string]string   `json:"throttle"`
+}
+
+// getFlowControl returns the queues of members of a replication group with the settings and counters of flow control.
+func getFlowControl(ctx context.Context, conn *dbConn, query string) (interface{}, int, error) {
+	members, err := queryContext(ctx, conn, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	variables, err := queryVariables(ctx, conn, "show global variables like 'group\\_replication\\_flow\\_control\\_%'")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	throttle, err := queryVariables(ctx, conn, "show global status like 'Gr\\_flow\\_control\\_%'")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jsonData, err := json.Marshal(flowControl{Members: members, Variables: variables, Throttle: throttle})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(members), nil
+}
+
+// clusterQuery returns the cluster this instance is registered in by the version 2 metadata of MySQL Shell,
+// the role is empty in a ReplicaSet whose members are not in a replication group.
+const clusterQuery = `select c.cluster_name, c.cluster_type,
+	if(c.primary_mode = 'mm', 'Multi-Primary', 'Single-Primary') as topology_mode,
+	i.instance_name, i.address, coalesce(m.member_role, '') as member_role
+	from mysql_innodb_cluster_metadata.instances i
+	join mysql_innodb_cluster_metadata.clusters c on c.cluster_id = i.cluster_id
+	left join performance_schema.replication_group_members m on m.member_id = i.mysql_server_uuid
+	where i.mysql_server_uuid = @@server_uuid`
@@ -0,0 +1,153 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// gtidQuery returns GTID variables of MySQL, MariaDB uses the variants of its own GTID implementation.
+const gtidQuery = `show global variables where variable_name in ('gtid_mode', 'gtid_executed', 'gtid_purged')`
+
+var gtidVariants = []queryVariant{
+	{family: familyMariaDB, query: `show global variables
+		where variable_name in ('gtid_binlog_pos', 'gtid_slave_pos', 'gtid_current_pos', 'gtid_strict_mode')`},
+}
+
+// gtidSet removes line breaks the server inserts between the sources of a GTID set.
+func gtidSet(set string) string {
+	return strings.Replace(set, "\n", "", -1)
+}
+
+// getGTID returns GTID variables of the server as a JSON object.
+func getGTID(ctx context.Context, conn *dbConn, query string) (interface{}, int, error) {
+	variables, err := queryVariables(ctx, conn, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(variables) == 0 {
+		return nil, 0, errorEmptyResult
+	}
+
+	for name, value := range variables {
+		variables[name] = gtidSet(value)
+	}
+
+	jsonData, err := json.Marshal(variables)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(variables), nil
+}
+
+// errantQuery returns transactions executed on a replica which are not in a GTID set of its source.
+const errantQuery = "select gtid_subtract(@@global.gtid_executed, ?)"
+
+// errantGTID is the result of mysql.gtid.errant.
+type errantGTID struct {
+	Errant       string `json:"errant"`
+	Transactions int64  `json:"transactions"`
+}
+
+// countGTIDs returns the number of transactions in a GTID set like uuid:1-5:7,uuid:1-3.
+func countGTIDs(set string) (count int64) {
+	for _, source := range strings.Split(set, ",") {
+		parts := strings.Split(strings.TrimSpace(source), ":")
+		for _, interval := range parts[1:] {
+			bounds := strings.SplitN(interval, "-", 2)
+
+			first, err := strconv.ParseInt(bounds[0], 10, 64)
+			if err != nil {
+				// Tags of MySQL 8.3 are not intervals.
+				continue
+			}
+
+			last := first
+			if len(bounds) == 2 {
+				if last, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+					continue
+				}
+			}
+			count += last - first + 1
+		}
+	}
+
+	return
+}
+
+// sourceConnection returns a connection to the source given by a session name or a URI.
+// A URI without credentials uses the credentials of the replica session.
+func (p *Plugin) sourceConnection(ctx context.Context, source string, replica *Session) (*dbConn, error) {
+	sessionName := source
+
+	session, ok, err := p.findSession(source)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		adHoc, err := p.connMgr.adHocSession(source)
+		if err != nil {
+			return nil, err
+		}
+
+		session = &Session{Uri: adHoc.Uri, User: adHoc.User, Password: adHoc.Password, Params: adHoc.Params}
+		if len(session.User) == 0 {
+			session.User = replica.User
+			session.Password = replica.Password
+		}
+		sessionName = ""
+	}
+
+	mysqlConf, err := p.getConfigDSN(session)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.connMgr.GetConnection(ctx, mysqlConf, splitStatements(session.InitStatements), session.maxLifetime(), sessionName)
+}
+
+// getErrantGTID returns transactions executed on a replica but not on its source.
+func getErrantGTID(ctx context.Context, replica, source *dbConn) (interface{}, error) {
+	var executed, errant string
+
+	row := source.connection.QueryRowContext(ctx, "select @@global.gtid_executed")
+	if err := row.Scan(&executed); err != nil {
+		return nil, err
+	}
+
+	row = replica.connection.QueryRowContext(ctx, errantQuery, executed)
+	if err := row.Scan(&errant); err != nil {
+		return nil, err
+	}
+
+	errant = gtidSet(errant)
+
+	jsonData, err := json.Marshal(errantGTID{Errant: errant, Transactions: countGTIDs(errant)})
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,272 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// exportRequest is an export of a metric on a connection served by the handler of the metric.
+type exportRequest struct {
+	ctx     context.Context
+	conn    *dbConn
+	key     string
+	props   *key // props is the metric with the query resolved for the server
+	params  []string
+	session *Session
+	names   nameFilter
+	lld     string
+}
+
+// param returns a parameter of the export, empty if it is not given.
+func (r *exportRequest) param(i int) string {
+	if len(r.params) > i {
+		return r.params[i]
+	}
+
+	return ""
+}
+
+// handler serves a metric on a connection, it returns the result and the number of rows it consists of.
+type handler func(p *Plugin, r *exportRequest) (result interface{}, rows int, err error)
+
+// scalar adapts a function returning a single value to a handler.
+func scalar(get func(p *Plugin, r *exportRequest) (interface{}, error)) handler {
+	return func(p *Plugin, r *exportRequest) (interface{}, int, error) {
+		result, err := get(p, r)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return result, 1, nil
+	}
+}
+
+// queryResult runs the query of a metric without a handler, as JSON rows or as a single value.
+func (p *Plugin) queryResult(r *exportRequest, args ...interface{}) (interface{}, int, error) {
+	if !r.props.json {
+		return scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+			return getOne(r.ctx, r.conn, r.props, args...)
+		})(p, r)
+	}
+
+	result, rows, err := p.getJSON(r.ctx, r.conn, r.key, r.props.query, r.props.lld, r.names, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return wrapLLD(result, r.lld), rows, nil
+}
+
+// handleQuery serves a metric by its query alone.
+func handleQuery(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return p.queryResult(r)
+}
+
+// handleObjectDiscovery serves discovery of objects within a database given as the fourth parameter.
+func handleObjectDiscovery(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return p.queryResult(r, r.params[3])
+}
+
+func handleDBSize(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	if len(r.params[3]) == 0 {
+		return nil, 0, errorDBnameMissing
+	}
+
+	mode, err := sizeMode(r.params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if mode == sizeBreakdown {
+		return scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+			return p.getSizeBreakdown(r.ctx, r.conn, r.params[3])
+		})(p, r)
+	}
+
+	return p.queryResult(r, r.params[3])
+}
+
+func handleFragmentation(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	if len(r.params[3]) == 0 {
+		return nil, 0, errorDBnameMissing
+	}
+
+	return p.getFragmentation(r.ctx, r.conn, r.params)
+}
+
+func handleAutoIncrement(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return p.getAutoIncrement(r.ctx, r.conn, r.params)
+}
+
+var handlePartitionSize = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return p.getPartitionSize(r.ctx, r.conn, r.params)
+})
+
+func handleIndexStats(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	if len(r.params[3]) == 0 {
+		return nil, 0, errorDBnameMissing
+	}
+
+	return p.getIndexStats(r.ctx, r.conn, r.params)
+}
+
+var handleStatusVariable = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return getVariable(r.ctx, r.conn, r.props.query, r.params[3])
+})
+
+var handleVariable = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	result, err := getOne(r.ctx, r.conn, r.props, r.params[3])
+	if err == sql.ErrNoRows {
+		return nil, errorUnknownVariable
+	}
+
+	return result, err
+})
+
+// handleVersion serves the version as text, or as a JSON object if the fourth parameter is json.
+func handleVersion(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	switch r.param(3) {
+	case "":
+		return p.queryResult(r)
+	case versionJSON:
+		return scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+			return getVersionJSON(r.ctx, r.conn)
+		})(p, r)
+	}
+
+	return nil, 0, errorVersionMode
+}
+
+var handleFlavor = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return getFlavor(r.ctx, r.conn)
+})
+
+var handleReadOnly = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return getReadOnly(r.ctx, r.conn)
+})
+
+var handleHeartbeat = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return getHeartbeatDelay(r.ctx, r.conn, p.options.HeartbeatSchema, p.options.HeartbeatTable, r.param(3))
+})
+
+// replicaQuery returns the query of the replica status limited to the channel given by the fourth parameter.
+func replicaQuery(r *exportRequest) (string, error) {
+	if channel := r.param(3); channel != "" {
+		return forChannel(r.ctx, r.conn, r.props.query, channel)
+	}
+
+	return r.props.query, nil
+}
+
+// replicaScalar adapts a function of the replica status of a channel returning a single value to a handler.
+func replicaScalar(get func(p *Plugin, r *exportRequest, query string) (interface{}, error)) handler {
+	return scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+		query, err := replicaQuery(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return get(p, r, query)
+	})
+}
+
+var handleReplicationLag = replicaScalar(func(p *Plugin, r *exportRequest, query string) (interface{}, error) {
+	return getReplicationLag(r.ctx, r.conn, query, p.options.ReplicationLagNull)
+})
+
+var handleReplicationErrors = replicaScalar(func(p *Plugin, r *exportRequest, query string) (interface{}, error) {
+	return getReplicationErrors(r.ctx, r.conn, query)
+})
+
+var handleRelayLogSpace = replicaScalar(func(p *Plugin, r *exportRequest, query string) (interface{}, error) {
+	return getRelayLogSpace(r.ctx, r.conn, query)
+})
+
+var handleRelayLogFiles = replicaScalar(func(p *Plugin, r *exportRequest, query string) (interface{}, error) {
+	return getRelayLogFiles(r.ctx, r.conn, query, r.param(3))
+})
+
+// handleReplicaStatus serves the replica status of the channel given by the fourth parameter.
+func handleReplicaStatus(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	query, err := replicaQuery(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	props := *r.props
+	props.query = query
+	channel := *r
+	channel.props = &props
+
+	return p.queryResult(&channel)
+}
+
+var handleErrantGTID = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	if len(r.params[3]) == 0 {
+		return nil, errorSourceMissing
+	}
+
+	source, err := p.sourceConnection(r.ctx, r.params[3], r.session)
+	if err != nil {
+		return nil, err
+	}
+
+	return getErrantGTID(r.ctx, r.conn, source)
+})
+
+func handleBinlogSize(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getBinlogSize(r.ctx, r.conn)
+}
+
+func handleBinlogRetention(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getBinlogRetention(r.ctx, r.conn)
+}
+
+func handleFlowControl(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getFlowControl(r.ctx, r.conn, r.props.query)
+}
+
+var handleGalera = scalar(func(p *Plugin, r *exportRequest) (interface{}, error) {
+	return getGalera(r.ctx, r.conn, r.key)
+})
+
+func handleGTID(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getGTID(r.ctx, r.conn, r.props.query)
+}
+
+func handleCharsets(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getCharsets(r.ctx, r.conn)
+}
+
+func handlePluginsList(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	return getPluginsList(r.ctx, r.conn)
+}
+
+func handleLogStats(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	result, err := getLogStats(r.ctx, r.conn, r.key)
+	return result, 0, err
+}
+
+func handleLogEntries(p *Plugin, r *exportRequest) (interface{}, int, error) {
+	result, err := getLogEntries(r.ctx, r.conn, r.key, r.param(3))
+	return result, 0, err
+}
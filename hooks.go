@@ -0,0 +1,49 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+// ResultHook transforms a result of a metric before it is returned to the agent.
+// Metrics returning JSON pass it as a string.
+type ResultHook func(key string, params []string, result interface{}) (interface{}, error)
+
+var resultHooks = make(map[string][]ResultHook)
+
+// RegisterResultHook adds a hook for a given metric key. Hooks of the same key are applied in the order of registration.
+// It is not thread-safe and must be called from an init function only.
+func RegisterResultHook(key string, hook ResultHook) {
+	if _, ok := keys[key]; !ok {
+		panic("cannot register a result hook for unknown key " + key)
+	}
+
+	resultHooks[key] = append(resultHooks[key], hook)
+}
+
+// applyResultHooks passes a result through all hooks registered for a given key.
+func applyResultHooks(key string, params []string, result interface{}) (interface{}, error) {
+	var err error
+
+	for _, hook := range resultHooks[key] {
+		if result, err = hook(key, params, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
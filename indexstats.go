@@ -0,0 +1,41 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "context"
+
+// indexStatsQuery returns I/O counters and latency in seconds of indexes, reads and writes without an index have an empty name.
+const indexStatsQuery = `select object_name as table_name, coalesce(index_name, '') as index_name,
+	count_read, count_write, count_fetch, count_insert, count_update, count_delete,
+	round(sum_timer_read / 1000000000000, 6) as read_time, round(sum_timer_write / 1000000000000, 6) as write_time
+	from performance_schema.table_io_waits_summary_by_index_usage
+	where object_schema = ? and (? = '' or object_name = ?)
+	order by count_read desc, object_name, index_name`
+
+// getIndexStats returns I/O statistics of indexes of a database given as the fourth parameter,
+// only of a table given as the fifth parameter if there is one.
+func (p *Plugin) getIndexStats(ctx context.Context, conn *dbConn, params []string) (interface{}, int, error) {
+	table := ""
+	if len(params) > 4 {
+		table = params[4]
+	}
+
+	return p.getNumericRows(ctx, conn, indexStatsQuery, params[3], table, table)
+}
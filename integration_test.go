@@ -0,0 +1,304 @@
+//go:build integration
+// +build integration
+
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+// The integration tests run every key against servers started in Docker containers:
+//
+//	go test -tags integration
+//
+// MYSQL_TEST_IMAGES overrides the comma separated list of images to test.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"zabbix.com/pkg/plugin"
+)
+
+const (
+	testImages   = "mysql:5.7,mysql:8.0,mariadb:10.4,percona:8.0"
+	testPassword = "zabbix"
+	testStartup  = 3 * time.Minute
+)
+
+// expectedErrors lists keys which cannot succeed on a standalone server without additional session settings.
+var expectedErrors = map[string]error{
+	"mysql.replication.get_replica_status": errorNoReplication,
+	"mysql.replication.lag":                errorNoReplication,
+	"mysql.replication.heartbeat":          errorNoHeartbeatTable,
+	"mysql.ports.probe":                    errorProbeNoPorts,
+	"mysql.admin.health":                   errorAdminNoPort,
+	"mysql.slow_log.stats":                 errorLogOutput,
+	"mysql.slow_log.entries":               errorLogOutput,
+	"mysql.general_log.stats":              errorLogOutput,
+	"mysql.service.state":                  errorServiceWindows,
+	"mysql.partition.size":                 errorNoPartition,
+}
+
+type testServer struct {
+	image     string
+	container string
+	uri       string
+}
+
+// startServer runs a container of a given image and waits until the server accepts connections.
+func startServer(t *testing.T, image string) *testServer {
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-P", "-e", "MYSQL_ROOT_PASSWORD="+testPassword, image).Output()
+	if err != nil {
+		t.Fatalf("cannot start %s: %s", image, err)
+	}
+
+	s := &testServer{image: image, container: strings.TrimSpace(string(out))}
+
+	out, err = exec.Command("docker", "port", s.container, "3306/tcp").Output()
+	if err != nil {
+		s.stop()
+		t.Fatalf("cannot get the port of %s: %s", image, err)
+	}
+
+	addr := strings.Fields(string(out))[0]
+	addr = strings.Replace(addr, "0.0.0.0", "127.0.0.1", 1)
+	s.uri = "tcp://" + addr
+
+	conf := mysql.NewConfig()
+	conf.User = "root"
+	conf.Passwd = testPassword
+	conf.Net = "tcp"
+	conf.Addr = addr
+	conf.AllowNativePasswords = true
+
+	db, err := sql.Open("mysql", conf.FormatDSN())
+	if err != nil {
+		s.stop()
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for deadline := time.Now().Add(testStartup); ; time.Sleep(time.Second) {
+		if err = db.Ping(); err == nil {
+			return s
+		}
+
+		if time.Now().After(deadline) {
+			s.stop()
+			t.Fatalf("%s has not started: %s", image, err)
+		}
+	}
+}
+
+func (s *testServer) stop() {
+	exec.Command("docker", "rm", "-f", s.container).Run()
+}
+
+// startPlugin configures and starts the plugin with a session named "test" pointing to a server. The options pass
+// through Configure so that every option not listed here gets its default value.
+func startPlugin(s *testServer) {
+	options := fmt.Sprintf(`Uri=%[1]s
+User=root
+Password=%[2]s
+EnableDiagnostics=1
+Sessions.test.Uri=%[1]s
+Sessions.test.User=root
+Sessions.test.Password=%[2]s
+Sessions.tls.Uri=%[1]s
+Sessions.tls.User=root
+Sessions.tls.Password=%[2]s
+Sessions.tls.Params.tls=skip-verify
+`, s.uri, testPassword)
+
+	impl.Configure(&plugin.GlobalOptions{Timeout: 10}, []byte(options))
+	impl.Start()
+}
+
+func TestIntegration(t *testing.T) {
+	images := os.Getenv("MYSQL_TEST_IMAGES")
+	if len(images) == 0 {
+		images = testImages
+	}
+
+	for _, image := range splitList(images) {
+		t.Run(image, func(t *testing.T) {
+			s := startServer(t, image)
+			defer s.stop()
+
+			startPlugin(s)
+			defer impl.Stop()
+
+			t.Run("keys", testKeys)
+			t.Run("tls", func(t *testing.T) { testTLS(t, s) })
+			t.Run("eviction", testEviction)
+		})
+	}
+}
+
+// testKeys exports every key supported by the server with the session and with a URI given as a parameter.
+func testKeys(t *testing.T) {
+	mysqlConf, err := impl.getConfigDSN(impl.options.Sessions["test"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := impl.connMgr.GetConnection(context.Background(), mysqlConf, nil, 0, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := conn.serverInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		k := keys[name]
+		if !info.has(k.requires) {
+			continue
+		}
+
+		var params []string
+		switch {
+		case k.maxParams == 0:
+		case name == "mysql.status_variable":
+			params = []string{"test", "", "", "Uptime"}
+		case name == "mysql.variable":
+			params = []string{"test", "", "", "read_only"}
+		case name == "mysql.gtid.errant":
+			params = []string{"test", "", "", "test"}
+		case k.minParams == 6:
+			params = []string{"test", "", "", "mysql", "user", "p0"}
+		case k.minParams == 4:
+			params = []string{"test", "", "", "mysql"}
+		case name == "mysql.plugin.querylog":
+			params = []string{"0"}
+		case name == "mysql.plugin.latency":
+			params = []string{"mysql.ping"}
+		default:
+			params = []string{"test"}
+		}
+
+		result, err := impl.Export(name, params, nil)
+		if expected, ok := expectedErrors[name]; ok {
+			if err != expected {
+				t.Errorf("%s: expected error %q, got %v", name, expected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		if k.json {
+			if str, ok := result.(string); !ok || !json.Valid([]byte(str)) {
+				t.Errorf("%s: the result is not valid JSON: %v", name, result)
+			}
+		}
+	}
+
+	if result, err := impl.Export("mysql.ping", []string{"", "root", testPassword}, nil); err != nil || result != int64(1) {
+		t.Errorf("mysql.ping with the default URI: %v, %v", result, err)
+	}
+}
+
+// testTLS checks that a session with the tls parameter connects with an encrypted connection.
+func testTLS(t *testing.T, s *testServer) {
+	if strings.HasPrefix(s.image, "mariadb") {
+		t.Skip("MariaDB images have no certificates by default")
+	}
+
+	if result, err := impl.Export("mysql.ping", []string{"tls"}, nil); err != nil || result != int64(1) {
+		t.Fatalf("mysql.ping over TLS: %v, %v", result, err)
+	}
+
+	mysqlConf, err := impl.getConfigDSN(impl.options.Sessions["tls"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := impl.connMgr.GetConnection(context.Background(), mysqlConf, nil, 0, "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var name, cipher string
+	if err = conn.db.QueryRow("show session status like 'Ssl_cipher'").Scan(&name, &cipher); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cipher) == 0 {
+		t.Error("the connection is not encrypted")
+	}
+}
+
+// testEviction checks that unused connections and ad hoc sessions are removed after the keepalive interval.
+func testEviction(t *testing.T) {
+	if _, err := impl.Export("mysql.ping", []string{"test"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c := impl.connMgr
+
+	c.RLock()
+	settings := c.connSettings
+	count := len(c.connections)
+	c.RUnlock()
+
+	if count == 0 {
+		t.Fatal("no connections are managed")
+	}
+
+	settings.keepAlive = time.Millisecond
+	c.reconfigure(settings)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := c.closeUnused(); err != nil {
+		t.Fatal(err)
+	}
+	c.evictAdHocSessions()
+
+	c.RLock()
+	defer c.RUnlock()
+
+	if len(c.connections) != 0 {
+		t.Errorf("%d unused connections are still open", len(c.connections))
+	}
+
+	if len(c.adHoc) != 0 {
+		t.Errorf("%d unused ad hoc sessions are still cached", len(c.adHoc))
+	}
+}
@@ -0,0 +1,145 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+type connInfo struct {
+	Number       uint64 `json:"number"`
+	Hash         string `json:"hash"`
+	Session      string `json:"session"`
+	Address      string `json:"address"`
+	User         string `json:"user"`
+	ConnectionID uint64 `json:"connection_id"`
+	Created      int64  `json:"created"`
+	LastAccess   int64  `json:"last_access"`
+	Ping         int    `json:"ping"`
+	Error        string `json:"error,omitempty"`
+}
+
+// describeConnections returns a description of each managed connection.
+// Connections are pinged concurrently outside of the lock.
+func (c *connManager) describeConnections() []connInfo {
+	c.RLock()
+
+	conns := make([]*dbConn, 0, len(c.connections))
+	infos := make([]connInfo, 0, len(c.connections))
+
+	for _, conn := range c.connections {
+		conns = append(conns, conn)
+		infos = append(infos, connInfo{
+			Number:       conn.num,
+			Hash:         conn.hash,
+			Session:      conn.session,
+			Address:      conn.addr,
+			User:         conn.user,
+			ConnectionID: conn.id(),
+			Created:      conn.created.Unix(),
+			LastAccess:   conn.lastTimeAccess().Unix(),
+		})
+	}
+
+	c.RUnlock()
+
+	var wg sync.WaitGroup
+
+	for i := range conns {
+		wg.Add(1)
+
+		go func(conn *dbConn, info *connInfo) {
+			defer wg.Done()
+
+			if err := c.ping(conn.db); err != nil {
+				info.Error = err.Error()
+				return
+			}
+			info.Ping = 1
+		}(conns[i], &infos[i])
+	}
+
+	wg.Wait()
+
+	return infos
+}
+
+// getConnections returns managed connections in JSON format.
+func (p *Plugin) getConnections() (result interface{}, err error) {
+	jsonData, err := json.Marshal(p.connMgr.describeConnections())
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
+
+type poolInfo struct {
+	Number             uint64  `json:"number"`
+	Hash               string  `json:"hash"`
+	Session            string  `json:"session"`
+	Address            string  `json:"address"`
+	User               string  `json:"user"`
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDuration       float64 `json:"wait_duration"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+// getPoolStats returns statistics of the connection pool of each managed connection in JSON format.
+func (p *Plugin) getPoolStats() (result interface{}, err error) {
+	c := p.connMgr
+
+	c.RLock()
+
+	infos := make([]poolInfo, 0, len(c.connections))
+	for _, conn := range c.connections {
+		s := conn.db.Stats()
+		infos = append(infos, poolInfo{
+			Number:             conn.num,
+			Hash:               conn.hash,
+			Session:            conn.session,
+			Address:            conn.addr,
+			User:               conn.user,
+			MaxOpenConnections: s.MaxOpenConnections,
+			OpenConnections:    s.OpenConnections,
+			InUse:              s.InUse,
+			Idle:               s.Idle,
+			WaitCount:          s.WaitCount,
+			WaitDuration:       s.WaitDuration.Seconds(),
+			MaxIdleClosed:      s.MaxIdleClosed,
+			MaxLifetimeClosed:  s.MaxLifetimeClosed,
+		})
+	}
+
+	c.RUnlock()
+
+	jsonData, err := json.Marshal(infos)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
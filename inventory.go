@@ -0,0 +1,175 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// inventoryEntry is a session definition provided by an inventory source.
+type inventoryEntry struct {
+	Name        string `json:"name"`
+	Uri         string `json:"uri"`
+	User        string `json:"user"`
+	Password    string `json:"password"`
+	PasswordRef string `json:"password_ref"`
+}
+
+// readInventory reads a JSON array of session definitions from an http, https or file URL.
+func readInventory(source string, timeout time.Duration) ([]inventoryEntry, error) {
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+
+	switch sourceURL.Scheme {
+	case "http", "https":
+		client := http.Client{Timeout: timeout}
+
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, errorInventoryStatus
+		}
+
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	case "file":
+		if data, err = ioutil.ReadFile(sourceURL.Path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errorInventoryURI
+	}
+
+	var entries []inventoryEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// loadInventory builds sessions from the inventory source. Invalid entries are skipped.
+func (p *Plugin) loadInventory(source string, opts *PluginOptions) (map[string]*Session, error) {
+	entries, err := readInventory(source, time.Duration(opts.Timeout)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secret references are resolved only in a local inventory. Anyone serving or altering a remote document
+	// could otherwise make the agent read local files or environment variables and send them to any host.
+	local := strings.HasPrefix(source, "file:")
+
+	sessions := make(map[string]*Session)
+
+	for _, e := range entries {
+		if err = checkSecret(e.Password, e.PasswordRef, opts.RequireSecretRefs == 1); err != nil {
+			p.Errf("cannot use the inventory session %s: %s", e.Name, err)
+			continue
+		}
+
+		if !local && len(e.PasswordRef) > 0 {
+			p.Errf("cannot use the inventory session %s: %s", e.Name, errorSecretRemote)
+			continue
+		}
+
+		password, err := secretPassword(e.Password, e.PasswordRef)
+		if err != nil {
+			p.Errf("cannot resolve the password of the inventory session %s: %s", e.Name, err)
+			continue
+		}
+
+		s := &Session{Uri: e.Uri, User: e.User, Password: password}
+		if opts.LenientURI == 1 {
+			s.Uri = normalizeURI(s.Uri)
+		}
+		setSessionDefaults(s, opts)
+
+		if _, err = checkURI(s); err != nil || len(e.Name) == 0 {
+			p.Errf("cannot use the inventory session %s: invalid definition", e.Name)
+			continue
+		}
+
+		sessions[e.Name] = s
+	}
+
+	return sessions, nil
+}
+
+// refreshInventory replaces inventory sessions and closes connections of removed and changed ones.
+func (p *Plugin) refreshInventory() {
+	p.optionsMutex.RLock()
+	source := p.options.InventorySource
+	opts := p.options
+	p.optionsMutex.RUnlock()
+
+	if len(source) == 0 {
+		return
+	}
+
+	sessions, err := p.loadInventory(source, &opts)
+	if err != nil {
+		p.Errf("cannot read the session inventory: %s", redactError(err))
+		return
+	}
+
+	p.optionsMutex.Lock()
+	defer p.optionsMutex.Unlock()
+
+	stale := p.staleConnections(p.inventory, sessions)
+	p.inventory = sessions
+
+	if p.connMgr != nil {
+		p.connMgr.closeStale(stale)
+	}
+
+	p.Debugf("Loaded %d sessions from the inventory", len(sessions))
+}
+
+// pollInventory periodically refreshes inventory sessions until the context is done.
+func (p *Plugin) pollInventory(ctx context.Context) {
+	for {
+		p.refreshInventory()
+
+		p.optionsMutex.RLock()
+		interval := time.Duration(p.options.InventoryInterval) * time.Second
+		p.optionsMutex.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// latencySamples is the number of the latest query durations kept for each key.
+const latencySamples = 1024
+
+// latencyBuckets are upper bounds of the histogram buckets of query durations.
+var latencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2500 * time.Millisecond,
+	5 * time.Second, 10 * time.Second,
+}
+
+// latencyRing stores the latest query durations of a key.
+type latencyRing struct {
+	samples [latencySamples]time.Duration
+	next    int
+	size    int
+}
+
+func (r *latencyRing) add(d time.Duration) {
+	r.samples[r.next] = d
+	r.next = (r.next + 1) % latencySamples
+
+	if r.size < latencySamples {
+		r.size++
+	}
+}
+
+// latencyBucket is a cumulative count of durations up to a bound in seconds, a null bound stands for infinity.
+type latencyBucket struct {
+	Le    *float64 `json:"le"`
+	Count int      `json:"count"`
+}
+
+type latencyInfo struct {
+	Samples int             `json:"samples"`
+	Min     float64         `json:"min"`
+	Max     float64         `json:"max"`
+	P50     float64         `json:"p50"`
+	P90     float64         `json:"p90"`
+	P99     float64         `json:"p99"`
+	Buckets []latencyBucket `json:"buckets"`
+}
+
+// percentile returns the duration below which a given share of sorted samples falls.
+func percentile(sorted []time.Duration, share float64) float64 {
+	return sorted[int(share*float64(len(sorted)-1))].Seconds()
+}
+
+// describe returns the histogram and percentiles of the stored durations.
+func (r *latencyRing) describe() latencyInfo {
+	sorted := make([]time.Duration, r.size)
+	copy(sorted, r.samples[:r.size])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	info := latencyInfo{Samples: r.size, Buckets: make([]latencyBucket, 0, len(latencyBuckets)+1)}
+
+	for _, bound := range latencyBuckets {
+		le := bound.Seconds()
+		count := sort.Search(len(sorted), func(i int) bool { return sorted[i] > bound })
+		info.Buckets = append(info.Buckets, latencyBucket{Le: &le, Count: count})
+	}
+	info.Buckets = append(info.Buckets, latencyBucket{Count: len(sorted)})
+
+	if len(sorted) > 0 {
+		info.Min = sorted[0].Seconds()
+		info.Max = sorted[len(sorted)-1].Seconds()
+		info.P50 = percentile(sorted, 0.5)
+		info.P90 = percentile(sorted, 0.9)
+		info.P99 = percentile(sorted, 0.99)
+	}
+
+	return info
+}
+
+// getLatency returns the histogram of the latest query durations of a key in JSON format.
+func getLatency(key string) (result interface{}, err error) {
+	if _, ok := keys[key]; !ok {
+		return nil, errorLatencyKey
+	}
+
+	stats.Lock()
+	ks := stats.key(key)
+	info := ks.latency.describe()
+	stats.Unlock()
+
+	jsonData, err := json.Marshal(&info)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,51 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "fmt"
+
+// limitError reports a result exceeding a limit set by the options.
+type limitError struct {
+	option string
+	limit  int
+	unit   string
+}
+
+func (e limitError) Error() string {
+	return fmt.Sprintf("The result exceeds %s of %d %s", e.option, e.limit, e.unit)
+}
+
+// checkRows checks the number of rows of a result against MaxRows.
+func (p *Plugin) checkRows(rows int) error {
+	if p.options.MaxRows > 0 && rows > p.options.MaxRows {
+		return limitError{option: "MaxRows", limit: p.options.MaxRows, unit: "rows"}
+	}
+
+	return nil
+}
+
+// checkSize checks the size of a serialized result against MaxResultBytes.
+func (p *Plugin) checkSize(data []byte) error {
+	if p.options.MaxResultBytes > 0 && len(data) > p.options.MaxResultBytes {
+		return limitError{option: "MaxResultBytes", limit: p.options.MaxResultBytes, unit: "bytes"}
+	}
+
+	return nil
+}
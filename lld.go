@@ -0,0 +1,119 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// discoveredNames maps discovery keys filtered by name to the field holding the name.
+var discoveredNames = map[string]string{
+	"mysql.db.discovery":        "Database",
+	"mysql.table.discovery":     "Table",
+	"mysql.partition.discovery": "Table",
+}
+
+// Envelopes of discovery results.
+const (
+	lldArray = "array"
+	lldData  = "data"
+)
+
+// lldFormat returns the envelope of a discovery result given as the fourth parameter or set by LLDFormat.
+// Discovery keys requiring the fourth parameter for another purpose use LLDFormat only.
+func (p *Plugin) lldFormat(key string, params []string) (string, error) {
+	format := p.options.LLDFormat
+	if keys[key].minParams < 4 && len(params) > 3 && len(params[3]) > 0 {
+		format = params[3]
+	}
+
+	if format != lldArray && format != lldData {
+		return "", errorLLDFormat
+	}
+
+	return format, nil
+}
+
+// wrapLLD wraps a discovery result in the data object expected by Zabbix versions before 4.2.
+func wrapLLD(result interface{}, format string) interface{} {
+	if s, ok := result.(string); ok && format == lldData {
+		return `{"data":` + s + `}`
+	}
+
+	return result
+}
+
+// macroPattern matches names of LLD macros.
+var macroPattern = regexp.MustCompile(`^\{#[A-Z0-9_.]+\}$`)
+
+// macroNames is a compiled form of MacroRule mapping fields to LLD macros.
+type macroNames map[string]string
+
+// newMacroNames compiles a macro rule defined in the configuration.
+func newMacroNames(r *MacroRule) (macroNames, error) {
+	if k, ok := keys[r.Key]; !ok || !k.lld {
+		return nil, errorMacroKey
+	}
+
+	names := make(macroNames)
+	for _, pair := range splitList(r.Names) {
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || len(parts[0]) == 0 || !macroPattern.MatchString(parts[1]) {
+			return nil, errorMacroName
+		}
+		names[parts[0]] = parts[1]
+	}
+
+	return names, nil
+}
+
+// macro returns the LLD macro of a field.
+func (n macroNames) macro(field string) string {
+	if macro, ok := n[field]; ok {
+		return macro
+	}
+
+	return "{#" + strings.ToUpper(field) + "}"
+}
+
+// applyMacros names fields of discovered objects as LLD macros if a macro rule is configured for a key.
+func (p *Plugin) applyMacros(key string, data interface{}) interface{} {
+	names, ok := p.macros[key]
+	if !ok {
+		return data
+	}
+
+	objects, ok := data.([]map[string]string)
+	if !ok {
+		return data
+	}
+
+	result := make([]map[string]string, 0, len(objects))
+	for _, obj := range objects {
+		entry := make(map[string]string, len(obj))
+		for field, value := range obj {
+			entry[names.macro(field)] = value
+		}
+		result = append(result, entry)
+	}
+
+	return result
+}
@@ -0,0 +1,165 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultLogEntries is the number of slow log entries returned if no limit is given.
+const defaultLogEntries = 20
+
+// topLogUsers is the number of the most active accounts reported in log aggregates.
+const topLogUsers = 5
+
+// logTables describes log tables of the server read by the metrics and their time columns.
+var logTables = map[string][2]string{
+	"mysql.slow_log.stats":    {"mysql.slow_log", "start_time"},
+	"mysql.slow_log.entries":  {"mysql.slow_log", "start_time"},
+	"mysql.general_log.stats": {"mysql.general_log", "event_time"},
+}
+
+// logWatermarks remembers the server time of the last poll of a log table for each connection and key,
+// so every poll only reads entries logged since the previous one.
+type logWatermarks struct {
+	sync.Mutex
+	last map[string]string
+}
+
+var watermarks = logWatermarks{last: make(map[string]string)}
+
+// swap stores the time of the current poll and returns the time of the previous one.
+// The current time is returned on the first poll, so no old entries are reported.
+func (w *logWatermarks) swap(target, now string) string {
+	w.Lock()
+	defer w.Unlock()
+
+	prev, ok := w.last[target]
+	w.last[target] = now
+
+	if !ok {
+		return now
+	}
+
+	return prev
+}
+
+type logUser struct {
+	UserHost string `json:"user_host"`
+	Count    int64  `json:"count"`
+}
+
+type logStats struct {
+	Since        string    `json:"since"`
+	Until        string    `json:"until"`
+	Count        int64     `json:"count"`
+	MaxQueryTime *float64  `json:"max_query_time,omitempty"`
+	TopUsers     []logUser `json:"top_users"`
+}
+
+// logWindow checks that the server logs to tables and returns the time window of the current poll.
+func logWindow(ctx context.Context, conn *dbConn, key string) (since, until string, err error) {
+	var output string
+	if err = conn.connection.QueryRowContext(ctx, "select @@log_output, now(6)").Scan(&output, &until); err != nil {
+		return
+	}
+
+	if !strings.Contains(strings.ToUpper(output), "TABLE") {
+		return "", "", errorLogOutput
+	}
+
+	return watermarks.swap(conn.hash+"\x00"+key, until), until, nil
+}
+
+// getLogStats returns the number of entries, the slowest query time and the most active accounts
+// of a log table since the last poll.
+func getLogStats(ctx context.Context, conn *dbConn, key string) (result interface{}, err error) {
+	since, until, err := logWindow(ctx, conn, key)
+	if err != nil {
+		return nil, err
+	}
+
+	table, column := logTables[key][0], logTables[key][1]
+	where := " from " + table + " where " + column + " > ? and " + column + " <= ?"
+	s := logStats{Since: since, Until: until, TopUsers: make([]logUser, 0, topLogUsers)}
+
+	if table == "mysql.slow_log" {
+		var maxTime float64
+		if err = conn.connection.QueryRowContext(ctx, "select count(*), coalesce(max(time_to_sec(query_time)), 0)"+where,
+			since, until).Scan(&s.Count, &maxTime); err != nil {
+			return nil, err
+		}
+		s.MaxQueryTime = &maxTime
+	} else if err = conn.connection.QueryRowContext(ctx, "select count(*)"+where, since, until).Scan(&s.Count); err != nil {
+		return nil, err
+	}
+
+	rows, err := queryContext(ctx, conn, "select user_host, count(*) as count"+where+
+		" group by user_host order by count desc limit "+strconv.Itoa(topLogUsers), since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		count, _ := strconv.ParseInt(row["count"], 10, 64)
+		s.TopUsers = append(s.TopUsers, logUser{UserHost: row["user_host"], Count: count})
+	}
+
+	jsonData, err := json.Marshal(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
+
+// getLogEntries returns the latest entries of the slow log since the last poll, at most a given number.
+func getLogEntries(ctx context.Context, conn *dbConn, key, limit string) (result interface{}, err error) {
+	n := defaultLogEntries
+	if len(limit) > 0 {
+		if n, err = strconv.Atoi(limit); err != nil || n <= 0 {
+			return nil, errorLogLimit
+		}
+	}
+
+	since, until, err := logWindow(ctx, conn, key)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := queryContext(ctx, conn, `select start_time, user_host, time_to_sec(query_time) as query_time,
+		time_to_sec(lock_time) as lock_time, rows_sent, rows_examined, db, left(sql_text, 1024) as sql_text
+		from mysql.slow_log where start_time > ? and start_time <= ?
+		order by start_time desc limit `+strconv.Itoa(n), since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,86 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+type keyInfo struct {
+	Key         string `json:"key"`
+	ValueType   string `json:"value_type"`
+	Units       string `json:"units,omitempty"`
+	Description string `json:"description"`
+	MinParams   int    `json:"min_params"`
+	MaxParams   int    `json:"max_params"`
+	LLD         bool   `json:"lld"`
+}
+
+// String returns the name of a value type.
+func (vt valueType) String() string {
+	switch vt {
+	case valueInt:
+		return "int"
+	case valueFloat:
+		return "float"
+	}
+
+	return "text"
+}
+
+// typeName returns the name of the type of a metric's result, JSON metrics have the json type.
+func (k *key) typeName() string {
+	if k.json {
+		return "json"
+	}
+
+	return k.valueType.String()
+}
+
+// getKeys returns metadata of all keys sorted by names in JSON format.
+func getKeys() (result interface{}, err error) {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]keyInfo, 0, len(names))
+	for _, name := range names {
+		k := keys[name]
+		infos = append(infos, keyInfo{
+			Key:         name,
+			ValueType:   k.typeName(),
+			Units:       k.units,
+			Description: k.summary,
+			MinParams:   k.minParams,
+			MaxParams:   k.maxParams,
+			LLD:         k.lld,
+		})
+	}
+
+	jsonData, err := json.Marshal(infos)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -23,6 +23,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"sync"
 	"time"
 
 	"zabbix.com/pkg/plugin"
@@ -31,56 +32,532 @@ import (
 const pingFailed = "0"
 
 type key struct {
-	query     string // SQL request text
-	minParams int    // minParams defines the minimum number of parameters for metrics.
-	maxParams int    // maxParams defines the maximum number of parameters for metrics.
-	json      bool   // It's a flag that the result must be in JSON
-	lld       bool   // It's a flag that the result must be in JSON with the key names in uppercase
+	query     string         // SQL request text
+	minParams int            // minParams defines the minimum number of parameters for metrics.
+	maxParams int            // maxParams defines the maximum number of parameters for metrics.
+	json      bool           // It's a flag that the result must be in JSON
+	lld       bool           // It's a flag that the result must be in JSON with the key names in uppercase
+	valueType valueType      // valueType defines the type of a scalar result, it's text by default.
+	units     string         // units of a scalar result
+	summary   string         // summary is a longer description of a metric
+	requires  capability     // requires defines the capabilities a server must have to support a metric.
+	internal  bool           // It's a flag that the metric describes the plugin itself or is not meant for templates
+	discovery string         // discovery is the key of the LLD rule providing a parameter of a metric
+	lldParam  int            // lldParam is the index of the parameter set to the macro of the discovered object
+	variants  []queryVariant // variants replace the query on servers of other flavors or versions
+	handler   handler        // handler serves the metric, the query is run for a single value or JSON rows without it
+	once      bool           // It's a flag that the metric is not queried again after transient errors
 }
 
 var keys = map[string]key{
 	"mysql.get_status_variables": {query: "show global status",
 		minParams: 1,
-		maxParams: 3,
+		maxParams: 4,
+		json:      true,
+		lld:       false,
+		summary:   "Values of global status variables as a JSON object, only those matching an optional fourth parameter: a LIKE pattern like Innodb_% or a regular expression in slashes."},
+	"mysql.get_variables": {query: "show global variables",
+		minParams: 1,
+		maxParams: 4,
 		json:      true,
-		lld:       false},
+		lld:       false,
+		summary:   "Values of global system variables as a JSON object, only those matching an optional fourth parameter: a LIKE pattern like innodb_% or a regular expression in slashes."},
+	"mysql.status_variable": {query: "show global status like ?",
+		minParams: 4,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		summary:   "Value of a global status variable named by the fourth parameter, e.g. Threads_connected.",
+		handler:   handleStatusVariable},
+	"mysql.variable": {query: "select variable_value from performance_schema.global_variables where variable_name = ?",
+		minParams: 4,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		summary:   "Value of a global system variable named by the fourth parameter, e.g. read_only.",
+		variants: []queryVariant{
+			{family: familyMariaDB, query: "select variable_value from information_schema.global_variables where variable_name = ?"},
+			{family: familyMySQL, before: 50700, query: "select variable_value from information_schema.global_variables where variable_name = ?"},
+		},
+		handler: handleVariable},
+	"mysql.read_only": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Read-only state of the server: 0 - writable, 1 - read_only, 2 - super_read_only.",
+		handler:   handleReadOnly},
 	"mysql.ping": {query: "select '1'",
 		minParams: 1,
 		maxParams: 3,
 		json:      false,
-		lld:       false},
+		lld:       false,
+		valueType: valueInt,
+		summary:   "1 if the server responds to a query, 0 otherwise."},
 	"mysql.version": {query: "select version()",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		summary:   "Version string of the server as returned by version(), or a JSON object with the flavor, major, minor and patch numbers and the version comment if the fourth parameter is json.",
+		handler:   handleVersion},
+	"mysql.flavor": {query: "",
 		minParams: 1,
 		maxParams: 3,
 		json:      false,
-		lld:       false},
+		lld:       false,
+		summary:   "Flavor of the server: mysql, mariadb, percona, aurora or tidb.",
+		handler:   handleFlavor},
 	"mysql.db.discovery": {query: "show databases",
 		minParams: 1,
-		maxParams: 3,
+		maxParams: 6,
+		json:      true,
+		lld:       true,
+		summary:   "Low-level discovery of databases, wrapped in a data object if the fourth parameter is data. The fifth and sixth parameters are regular expressions of databases to include and exclude, system schemas are excluded by default."},
+	"mysql.table.discovery": {query: "select table_schema as `Schema`, table_name as `Table`, coalesce(engine, '') as `Engine` " +
+		"from information_schema.tables where table_schema = ? and table_type = 'BASE TABLE' order by table_name",
+		minParams: 4,
+		maxParams: 6,
 		json:      true,
-		lld:       true},
+		lld:       true,
+		summary:   "Low-level discovery of tables of a database given as the fourth parameter. The fifth and sixth parameters are regular expressions of tables to include and exclude.",
+		handler:   handleObjectDiscovery},
 	"mysql.db.size": {query: "select coalesce(sum(data_length + index_length),0) from information_schema.tables where table_schema=?",
 		minParams: 4,
+		maxParams: 5,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		units:     "B",
+		summary:   "Total size of data and indexes of a database given as the fourth parameter, or a JSON object of data_length, index_length, data_free and rows if the fifth parameter is breakdown.",
+		discovery: "mysql.db.discovery",
+		lldParam:  3,
+		handler:   handleDBSize},
+	"mysql.db.sizes": {query: `select s.schema_name as name, coalesce(sum(t.data_length + t.index_length), 0) as size
+		from information_schema.schemata s left join information_schema.tables t on t.table_schema = s.schema_name
+		group by s.schema_name order by s.schema_name`,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Total size of data and indexes of every database in bytes as a JSON array, in one scan of information_schema.tables."},
+	"mysql.table.fragmentation": {query: "",
+		minParams: 4,
+		maxParams: 5,
+		json:      true,
+		lld:       false,
+		summary:   "Free space of tables of a database given as the fourth parameter and its share of the allocated space, only of a table given as the fifth parameter if there is one.",
+		discovery: "mysql.table.discovery",
+		lldParam:  4,
+		handler:   handleFragmentation},
+	"mysql.auto_increment": {query: "",
+		minParams: 1,
 		maxParams: 4,
+		json:      true,
+		lld:       false,
+		summary:   "Next value of each AUTO_INCREMENT column, the maximum of its type and the used ratio, of all databases or of a database given as the fourth parameter.",
+		handler:   handleAutoIncrement},
+	"mysql.index.unused": {query: "select object_schema, object_name, index_name from sys.schema_unused_indexes order by object_schema, object_name, index_name",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Indexes without reads since the server started, from sys.schema_unused_indexes.",
+		requires:  capSysSchema | capPerformanceSchema},
+	"mysql.index.redundant": {query: `select table_schema, table_name, redundant_index_name, redundant_index_columns,
+		dominant_index_name, dominant_index_columns, sql_drop_index
+		from sys.schema_redundant_indexes order by table_schema, table_name, redundant_index_name`,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Indexes duplicating other indexes with the statements dropping them, from sys.schema_redundant_indexes.",
+		requires:  capSysSchema},
+	"mysql.index.stats": {query: "",
+		minParams: 4,
+		maxParams: 5,
+		json:      true,
+		lld:       false,
+		summary:   "Reads, writes and their latency of each index of a database given as the fourth parameter, only of a table given as the fifth parameter if there is one.",
+		requires:  capPerformanceSchema,
+		discovery: "mysql.table.discovery",
+		lldParam:  4,
+		handler:   handleIndexStats},
+	"mysql.partition.discovery": {query: partitionDiscoveryQuery,
+		minParams: 4,
+		maxParams: 6,
+		json:      true,
+		lld:       true,
+		summary:   "Low-level discovery of partitions of tables of a database given as the fourth parameter. The fifth and sixth parameters are regular expressions of tables to include and exclude.",
+		handler:   handleObjectDiscovery},
+	"mysql.partition.size": {query: "",
+		minParams: 6,
+		maxParams: 6,
+		json:      true,
+		lld:       false,
+		summary:   "Number of rows and sizes of data, indexes and free space of a partition given by the database, the table and the partition name as the fourth to sixth parameters.",
+		discovery: "mysql.partition.discovery",
+		lldParam:  5,
+		handler:   handlePartitionSize},
+	"mysql.engines": {query: "show engines",
+		minParams: 1,
+		maxParams: 4,
+		json:      true,
+		lld:       true,
+		summary:   "Low-level discovery of storage engines with their support level (DEFAULT, YES, NO or DISABLED) and features, wrapped in a data object if the fourth parameter is data."},
+	"mysql.plugins.discovery": {query: "show plugins",
+		minParams: 1,
+		maxParams: 4,
+		json:      true,
+		lld:       true,
+		summary:   "Low-level discovery of server plugins with their status, type, library and license, wrapped in a data object if the fourth parameter is data."},
+	"mysql.galera.status": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "All wsrep_% status variables of a Galera node as a JSON object.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.ready": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Whether a Galera node accepts queries: 1 if wsrep_ready is ON, 0 otherwise.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.cluster_status": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		summary:   "Status of the cluster component of a Galera node, Primary or non-Primary.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.local_state": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		summary:   "State of a Galera node like Synced, Donor/Desynced or Joining.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.cluster_size": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
 		json:      false,
-		lld:       false},
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Number of nodes in the cluster component of a Galera node.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.primary": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Whether a Galera node is in the primary component with the quorum: 1 if wsrep_cluster_status is Primary, 0 otherwise.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.galera.flow": {query: galeraStatusQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Flow control pauses, send and receive queue averages and certification failures of a Galera node as a JSON object.",
+		requires:  capGalera,
+		handler:   handleGalera},
+	"mysql.gtid": {query: gtidQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "GTID mode, executed and purged sets of MySQL or binlog, replica and current positions of MariaDB as a JSON object.",
+		variants:  gtidVariants,
+		handler:   handleGTID},
+	"mysql.gtid.errant": {query: errantQuery,
+		minParams: 4,
+		maxParams: 4,
+		json:      true,
+		lld:       false,
+		summary:   "Errant transactions executed on a replica but not on the source given by a session name or a URI as the fourth parameter.",
+		requires:  capGTIDSets,
+		handler:   handleErrantGTID},
+	"mysql.charsets": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Default character set and collation of the server and of each database.",
+		handler:   handleCharsets},
+	"mysql.binlog.size": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Number and total size in bytes of binary logs as a JSON object.",
+		requires:  capBinlog,
+		handler:   handleBinlogSize},
+	"mysql.binlog.retention": {query: binlogRetentionQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Binary logging, sync_binlog and the retention of binary logs in seconds as a JSON object.",
+		handler:   handleBinlogRetention},
+	"mysql.group_replication.member": {query: groupMemberQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "State, role, queued transactions and detected conflicts of this member of a replication group as a JSON object.",
+		requires:  capGroupReplication | capPerformanceSchema,
+		variants:  groupMemberVariants},
+	"mysql.group_replication.flow_control": {query: flowControlQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Certifier and applier queues of members of a replication group with settings and throttle counters of flow control.",
+		requires:  capGroupReplication | capPerformanceSchema,
+		variants:  flowControlVariants,
+		handler:   handleFlowControl},
+	"mysql.innodb_cluster": {query: clusterQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Name, type and topology mode of the InnoDB Cluster or ReplicaSet this instance is registered in with its role.",
+		requires:  capClusterMetadata | capPerformanceSchema},
+	"mysql.master_status": {query: "show master status",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Current binary log file, position and executed GTID set of a source as a JSON object.",
+		requires:  capBinlog,
+		variants:  binlogStatusVariants},
 	"mysql.replication.discovery": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      true,
+		lld:       true,
+		summary:   "Low-level discovery of replication sources, wrapped in a data object if the fourth parameter is data.",
+		requires:  capReplica,
+		variants:  replicaDiscoveryVariants},
+	"mysql.replication.errors": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      true,
+		lld:       false,
+		summary:   "Classified last errors of the replication threads of a channel given by the fourth parameter, severity 0 - none, 1 - transient, 2 - permanent.",
+		requires:  capReplica,
+		variants:  replicaStatusVariants,
+		handler:   handleReplicationErrors},
+	"mysql.replication.relay_log_space": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		units:     "B",
+		summary:   "Total size of relay logs of a replica, of the channel given by the fourth parameter on a multi-source replica.",
+		requires:  capReplica,
+		variants:  replicaStatusVariants,
+		handler:   handleRelayLogSpace},
+	"mysql.replication.relay_log_files": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Number of relay logs of a replica, of the channel given by the fourth parameter on a multi-source replica.",
+		requires:  capReplica,
+		variants:  replicaStatusVariants,
+		handler:   handleRelayLogFiles},
+	"mysql.replication.get_replica_status": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      true,
+		lld:       false,
+		summary:   "Replication status of a replica as a JSON object, of the channel given by the fourth parameter on a multi-source replica.",
+		requires:  capReplica,
+		variants:  replicaStatusVariants,
+		handler:   handleReplicaStatus},
+	"mysql.replication.heartbeat": {query: "",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		valueType: valueFloat,
+		units:     "s",
+		summary:   "Replication delay measured by the pt-heartbeat table, of the source with the server_id given by the fourth parameter.",
+		handler:   handleHeartbeat},
+	"mysql.replication.lag": {query: "show slave status",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		valueType: valueFloat,
+		units:     "s",
+		summary:   "Seconds_Behind_Master of a replica, of the channel given by the fourth parameter on a multi-source replica.",
+		requires:  capReplica,
+		variants:  replicaStatusVariants,
+		handler:   handleReplicationLag},
+	"mysql.replication.workers": {query: workersQuery,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Applier workers of a multi-threaded replica with their state, lag, last error and retried transactions.",
+		requires:  capReplica | capPerformanceSchema,
+		variants:  workersVariants},
+	"mysql.roles": {query: `select e.from_user as role, e.from_host as role_host, e.to_user as user, e.to_host as user_host,
+		e.with_admin_option as admin_option, if(d.user is null, 'N', 'Y') as is_default
+		from mysql.role_edges e left join mysql.default_roles d on d.user = e.to_user and d.host = e.to_host
+		and d.default_role_user = e.from_user and d.default_role_host = e.from_host
+		order by e.to_user, e.to_host, e.from_user, e.from_host`,
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Roles granted to each account with the admin option and whether the role is a default one (MySQL 8.0).",
+		requires:  capRoles},
+	"mysql.plugins.list": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Installed server plugins with their status, type and library, and components of MySQL 8.0.",
+		handler:   handlePluginsList},
+	"mysql.slow_log.stats": {query: "",
 		minParams: 1,
 		maxParams: 3,
 		json:      true,
-		lld:       true},
-	"mysql.replication.get_slave_status": {query: "show slave status",
+		lld:       false,
+		summary:   "Number of slow queries, the longest query time and the most active accounts since the last poll, if log_output includes TABLE.",
+		handler:   handleLogStats,
+		once:      true},
+	"mysql.slow_log.entries": {query: "",
 		minParams: 1,
 		maxParams: 4,
 		json:      true,
-		lld:       false},
+		lld:       false,
+		summary:   "Latest slow log entries since the last poll, at most the number given as the fourth parameter (20 by default).",
+		handler:   handleLogEntries,
+		once:      true},
+	"mysql.general_log.stats": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Number of general log entries and the most active accounts since the last poll, if log_output includes TABLE.",
+		handler:   handleLogStats,
+		once:      true},
+	"mysql.admin.health": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "State of the classic and admin ports: 0 - both reachable, 1 - admin only, 2 - classic only, 3 - neither."},
+	"mysql.service.state": {query: "",
+		minParams: 1,
+		maxParams: 4,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "State of the Windows service of a local server named by the fourth parameter (MySQL by default): 0 - running, 1 - paused, 2 - start pending, 3 - pause pending, 4 - continue pending, 5 - stop pending, 6 - stopped, 7 - unknown, 255 - no such service.",
+		internal:  true},
+	"mysql.diagnostics": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Diagnostic bundle of status, waits, digests, replication and InnoDB status for support requests.",
+		internal:  true},
+	"mysql.ports.probe": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      true,
+		lld:       false,
+		summary:   "Reachability and latency of auxiliary ports configured for a session.",
+		internal:  true},
+	"mysql.plugin.connections": {query: "",
+		minParams: 0,
+		maxParams: 0,
+		json:      true,
+		lld:       false,
+		summary:   "Connections managed by the plugin with their state.",
+		internal:  true},
+	"mysql.plugin.stats": {query: "",
+		minParams: 0,
+		maxParams: 0,
+		json:      true,
+		lld:       false,
+		summary:   "Exports, errors and query latency of each key and connection counters of the plugin.",
+		internal:  true},
+	"mysql.plugin.pool": {query: "",
+		minParams: 0,
+		maxParams: 0,
+		json:      true,
+		lld:       false,
+		summary:   "Statistics of the connection pool of each managed connection.",
+		internal:  true},
+	"mysql.plugin.latency": {query: "",
+		minParams: 1,
+		maxParams: 1,
+		json:      true,
+		lld:       false,
+		summary:   "Histogram and percentiles in seconds of the latest query durations of a key given as the parameter.",
+		internal:  true},
+	"mysql.plugin.querylog": {query: "",
+		minParams: 0,
+		maxParams: 1,
+		json:      false,
+		lld:       false,
+		valueType: valueInt,
+		summary:   "Enables logging of SQL statements for a given number of seconds, returns the number of seconds left.",
+		internal:  true},
+	"mysql.keys": {query: "",
+		minParams: 0,
+		maxParams: 0,
+		json:      true,
+		lld:       false,
+		summary:   "Metadata of all keys supported by the plugin.",
+		internal:  true},
+	"mysql.template": {query: "",
+		minParams: 1,
+		maxParams: 3,
+		json:      false,
+		lld:       false,
+		summary:   "Zabbix template in XML format with the keys supported by the server.",
+		internal:  true},
 }
 
 // Plugin inherits plugin.Base and store plugin-specific data.
 type Plugin struct {
 	plugin.Base
-	connMgr *connManager
-	options PluginOptions
+	connMgr  *connManager
+	options  PluginOptions
+	filters  map[string]*fieldFilter
+	macros   map[string]macroNames
+	cacheTTL map[string]time.Duration
+	budget   timeoutBudget
+	patterns []*sessionPattern
+
+	// housekeeping triggers closing of unused connections and eviction of cached entries.
+	housekeeping *time.Ticker
+
+	// inventory stores sessions read from the inventory source.
+	inventory map[string]*Session
+
+	// optionsMutex protects options while the configuration is reloaded.
+	optionsMutex sync.RWMutex
 }
 
 type columnName = string
@@ -90,31 +567,50 @@ var impl Plugin
 
 var ctx, cancel = context.WithCancel(context.Background())
 
+// shutdownTimeout is the maximum time to wait for connections to be closed when the plugin is stopped or all
+// connections are closed by a reload.
+const shutdownTimeout = 5 * time.Second
+
 // Start deleting unused connections
 func (p *Plugin) Start() {
 	p.Debugf("func Start")
 
-	p.connMgr = newConnManager(
-		time.Duration(p.options.KeepAlive)*time.Second,
-		time.Duration(p.options.Timeout)*time.Second)
+	// The context is renewed as the plugin can be started again after it has been stopped.
+	ctx, cancel = context.WithCancel(context.Background())
+
+	p.connMgr = newConnManager(p.connSettings())
 
 	// Repeatedly check for unused connections and close them.
-	go func(ctx context.Context) {
-		ticker := time.NewTicker(10 * time.Second)
+	p.housekeeping = time.NewTicker(time.Duration(p.options.PurgeInterval) * time.Second)
+
+	go func(ctx context.Context, ticker *time.Ticker) {
 		for {
 			select {
 			case <-ctx.Done():
 				p.Debugf("stop goroutine")
-				ticker.Stop()
 				return
 			case <-ticker.C:
 				p.Debugf("func Start, closeUnused()")
 				if err := p.connMgr.closeUnused(); err != nil {
 					p.Errf("Error occurred while closing connection: %s", err.Error())
 				}
+				p.connMgr.evictAdHocSessions()
+				p.connMgr.evictFailures()
+				results.evict()
 			}
 		}
-	}(ctx)
+	}(ctx, p.housekeeping)
+
+	go p.pollInventory(ctx)
+
+	if len(p.options.TraceEndpoint) > 0 {
+		tracer = newSpanExporter(p.options.TraceEndpoint)
+		go tracer.run(ctx)
+	}
+
+	if p.options.WarmUp == 1 {
+		p.warmUp()
+	}
 }
 
 // Stop deleting unused connections
@@ -122,24 +618,86 @@ func (p *Plugin) Stop() {
 	p.Debugf("func Stop")
 
 	cancel()
-	p.connMgr.closeAllConn()
+	tracer = nil
+	p.housekeeping.Stop()
+	p.connMgr.closeAll(shutdownTimeout)
+	tunnels.closeAll()
 	p.connMgr = nil
 }
 
-// Export implements the Exporter interface.
+// checkParams checks the number of parameters of a metric.
+func checkParams(key string, params []string) error {
+	if len(params) > keys[key].maxParams {
+		return errorTooManyParameters
+	}
+
+	if len(params) < keys[key].minParams {
+		return errorTooFewParameters
+	}
+
+	return nil
+}
+
+// Export implements the Exporter interface. The options are read under the lock and the export runs on their
+// snapshot, so a reload does not wait for slow queries.
 func (p *Plugin) Export(key string, params []string, ctx plugin.ContextProvider) (result interface{}, err error) {
 	p.Debugf("func Export")
 
+	p.optionsMutex.RLock()
+	view := p.configView()
+	p.optionsMutex.RUnlock()
+
+	return view.export(key, params)
+}
+
+// configView returns a copy of the plugin state derived from the configuration. Configure and the inventory
+// replace the state rather than change it, so the copy stays consistent. It must be called under the options lock.
+func (p *Plugin) configView() *Plugin {
+	return &Plugin{
+		Base:      p.Base,
+		connMgr:   p.connMgr,
+		options:   p.options,
+		filters:   p.filters,
+		macros:    p.macros,
+		cacheTTL:  p.cacheTTL,
+		budget:    p.budget,
+		patterns:  p.patterns,
+		inventory: p.inventory,
+	}
+}
+
+// export serves a metric on a snapshot of the configuration.
+func (p *Plugin) export(key string, params []string) (result interface{}, err error) {
+	if current, ok := deprecatedKeys[key]; ok {
+		if err = p.useDeprecated(deprecatedName{kind: "key", old: key, current: current}); err != nil {
+			return nil, err
+		}
+		key = current
+	}
+
+	trace := exportTrace{start: time.Now(), session: "-", address: "-", rows: -1}
+	spanCtx, exportSpan := startTrace(context.Background(), "export", "zabbix.key", key)
+
+	defer func() {
+		if err == nil {
+			result, err = applyResultHooks(key, params, result)
+		} else {
+			err = p.toBusy(redactError(err))
+		}
+		stats.addExport(key, err)
+		exportSpan.end(err)
+
+		if p.options.DebugExports == 1 {
+			p.logExport(key, &trace, err)
+		}
+	}()
+
 	paramsSize := len(params)
 	username := ""
 	password := ""
 
-	if paramsSize > keys[key].maxParams {
-		return nil, errorTooManyParameters
-	}
-
-	if paramsSize < keys[key].minParams {
-		return nil, errorTooFewParameters
+	if err = checkParams(key, params); err != nil {
+		return nil, err
 	}
 
 	if paramsSize >= 2 {
@@ -150,7 +708,41 @@ func (p *Plugin) Export(key string, params []string, ctx plugin.ContextProvider)
 		password = params[2]
 	}
 
-	session, ok := p.options.Sessions[params[0]]
+	if key == "mysql.diagnostics" && p.options.EnableDiagnostics == 0 {
+		return nil, errorDiagnosticsOff
+	}
+
+	if key == "mysql.plugin.connections" {
+		return p.getConnections()
+	}
+
+	if key == "mysql.plugin.stats" {
+		return getStats()
+	}
+
+	if key == "mysql.plugin.pool" {
+		return p.getPoolStats()
+	}
+
+	if key == "mysql.keys" {
+		return getKeys()
+	}
+
+	if key == "mysql.plugin.latency" {
+		return getLatency(params[0])
+	}
+
+	if key == "mysql.plugin.querylog" {
+		return p.setQueryLog(params)
+	}
+
+	session, ok, err := p.findSession(params[0])
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		trace.session = params[0]
+	}
 	if ok && (len(username) > 0 || len(password) > 0) {
 		return nil, errorUserPassword
 	}
@@ -160,61 +752,252 @@ func (p *Plugin) Export(key string, params []string, ctx plugin.ContextProvider)
 		if len(url) == 0 {
 			url = p.options.Uri
 		}
+
+		adHoc, err := p.connMgr.adHocSession(url)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(adHoc.User) > 0 {
+			if len(username) > 0 || len(password) > 0 {
+				return nil, errorURICredentials
+			}
+			username = adHoc.User
+			password = adHoc.Password
+		}
+
 		if len(username) == 0 {
 			username = p.options.User
 		}
 		if len(password) == 0 {
 			password = p.options.Password
 		}
-		session = &Session{Uri: url, User: username, Password: password}
+		session = &Session{Uri: adHoc.Uri, User: username, Password: password, Params: adHoc.Params}
+	}
+
+	session = session.forKey(key)
+
+	allVariables := key == "mysql.get_status_variables" || key == "mysql.get_variables"
+
+	var names nameFilter
+	if allVariables && paramsSize > 3 && len(params[3]) > 0 {
+		if names.include, err = compileNamePattern(params[3]); err != nil {
+			return nil, err
+		}
+	}
+
+	if key == "mysql.db.discovery" {
+		if names, err = objectFilter(params, systemSchemas); err != nil {
+			return nil, err
+		}
+	}
+
+	if key == "mysql.table.discovery" || key == "mysql.partition.discovery" {
+		if len(params[3]) == 0 {
+			return nil, errorDBnameMissing
+		}
+
+		if names, err = objectFilter(params, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	lldFormat := lldArray
+	if keys[key].lld {
+		if lldFormat, err = p.lldFormat(key, params); err != nil {
+			return nil, err
+		}
+	}
+
+	// Variables of named sessions are served from the last collection while it is fresh.
+	if ok && allVariables {
+		if snap, fresh := p.getSnapshot(params[0]); fresh {
+			variables := matchNames(snap.forKey(key), names)
+
+			jsonData, err := json.Marshal(p.filterFields(key, variables))
+			if err != nil {
+				return nil, err
+			}
+			trace.rows = len(variables)
+
+			return string(jsonData), nil
+		}
+	}
+
+	if key == "mysql.status_variable" || key == "mysql.variable" {
+		if len(params[3]) == 0 {
+			return nil, errorVariableMissing
+		}
+
+		if ok {
+			if snap, fresh := p.getSnapshot(params[0]); fresh {
+				trace.rows = 1
+				return lookupVariable(snap.forKey(key), params[3])
+			}
+		}
+	}
+
+	if ok && key == "mysql.read_only" {
+		if snap, fresh := p.getSnapshot(params[0]); fresh {
+			return readOnlyState(snap.variables), nil
+		}
+	}
+
+	if ok && isGalera(key) {
+		if snap, fresh := p.getSnapshot(params[0]); fresh {
+			return galeraResult(key, snap.status)
+		}
+	}
+
+	// Auxiliary ports are probed without connecting to MySQL.
+	if key == "mysql.ports.probe" {
+		return p.probePorts(session)
+	}
+
+	// The service control manager is queried without connecting to MySQL.
+	if key == "mysql.service.state" {
+		return getServiceState(session, params)
 	}
 
 	mysqlConf, err := p.getConfigDSN(session)
 	if err != nil {
 		return nil, err
 	}
+	trace.address = mysqlConf.Addr
+	exportSpan.set("net.peer.name", mysqlConf.Addr, "mysql.session", trace.session)
+
+	// Both ports are checked with new connections rather than the managed one.
+	if key == "mysql.admin.health" {
+		return p.checkAdmin(session, mysqlConf)
+	}
+
+	initStatements := splitStatements(session.InitStatements)
+
+	// Cached results are stored before the result hooks are applied, so the hooks run on each export.
+	if ttl := p.cacheTTL[key]; ttl > 0 {
+		cacheKey := resultKey(mysqlConf, initStatements, key, params)
+		if cached, ok := results.get(cacheKey); ok {
+			return cached, nil
+		}
+
+		defer func() {
+			if err == nil {
+				results.put(cacheKey, result, ttl)
+			}
+		}()
+	}
 
-	conn, err := p.connMgr.GetConnection(mysqlConf)
+	sessionName := ""
+	if ok {
+		sessionName = params[0]
+	}
+
+	backend := backendID(mysqlConf)
+
+	conn, err := p.connMgr.GetConnection(spanCtx, mysqlConf, initStatements, session.maxLifetime(), sessionName)
 	if err != nil {
+		if err == errorCircuitOpen {
+			stats.addLoad(backend, false, true)
+		}
+
 		// Special logic of processing connection errors is used if mysql.ping is requested
 		// because it must return pingFailed if any error occurred.
 		if key == "mysql.ping" {
-			return pingFailed, nil
+			return convertValue(pingFailed, keys[key].valueType)
 		}
 		return nil, err
 	}
 
 	keyProperties := keys[key]
 
-	if key == "mysql.db.size" {
-		if len(params[3]) == 0 {
-			return nil, errorDBnameMissing
+	if key == "mysql.diagnostics" {
+		return p.getDiagnostics(conn, mysqlConf)
+	}
+
+	if key == "mysql.template" {
+		return p.getTemplate(conn, params[0])
+	}
+
+	queryCtx, queryCancel := context.WithTimeout(spanCtx, p.budget.query)
+	defer queryCancel()
+
+	queryStart := time.Now()
+	defer func() {
+		elapsed := time.Since(queryStart)
+		stats.addQuery(key, elapsed)
+		stats.addLoad(backend, p.isSlow(elapsed), false)
+
+		if threshold := time.Duration(p.options.SlowQueryThreshold) * time.Millisecond; threshold > 0 && elapsed >= threshold {
+			p.Warningf("Slow export %s: session %s, address %s, query time %s", key, trace.session, trace.address, elapsed)
 		}
+	}()
 
-		result, err = getOne(conn, &keyProperties, params[3])
-		if err != nil {
-			return
+	// A query which may be killed on timeout runs on a pinned physical connection, so the id of the connection is known.
+	var connID uint64
+	if p.options.KillOnTimeout == 1 {
+		var release func()
+		if conn, connID, release, err = conn.pin(queryCtx); err != nil {
+			return nil, err
 		}
+		defer release()
+	}
+
+	if err = resolveQuery(queryCtx, conn, &keyProperties); err != nil {
+		return nil, p.checkQuery(queryCtx, conn, connID, err)
+	}
+
+	req := &exportRequest{
+		ctx:     queryCtx,
+		conn:    conn,
+		key:     key,
+		props:   &keyProperties,
+		params:  params,
+		session: session,
+		names:   names,
+		lld:     lldFormat,
+	}
 
+	handle := keyProperties.handler
+	if handle == nil {
+		handle = handleQuery
+	}
+
+	var rows int
+	query := func() (r interface{}, err error) {
+		r, rows, err = handle(p, req)
 		return
 	}
 
-	if keyProperties.json {
-		return getJSON(conn, key)
+	// Log tables are not queried again on errors, as each poll moves the watermark of the table.
+	if keyProperties.once {
+		result, err = query()
+	} else {
+		result, err = p.withRetry(queryCtx, query)
 	}
+	if err != nil {
+		return nil, p.checkQuery(queryCtx, conn, connID, err)
+	}
+	trace.rows = rows
 
-	return getOne(conn, &keyProperties)
+	return result, nil
 }
 
 // Get a single value
-func getOne(config *dbConn, keyProperties *key, args ...interface{}) (result interface{}, err error) {
+func getOne(ctx context.Context, config *dbConn, keyProperties *key, args ...interface{}) (result interface{}, err error) {
 
 	var col interface{}
-	if err = config.connection.QueryRow(keyProperties.query, args...).Scan(&col); err != nil {
+
+	start := time.Now()
+	ctx, querySpan := startSpan(ctx, "query", "db.statement", keyProperties.query)
+	err = config.connection.QueryRowContext(ctx, keyProperties.query, args...).Scan(&col)
+	querySpan.end(err)
+	queryLog.record(config, keyProperties.query, start, 1, err)
+
+	if err != nil {
 		return
 	}
 
-	return string(col.([]byte)), nil
+	return convertValue(valueToString(col), keyProperties.valueType)
 }
 
 func rows2data(rows *sql.Rows) (result []map[string]string, err error) {
@@ -242,11 +1025,7 @@ func rows2data(rows *sql.Rows) (result []map[string]string, err error) {
 		entry := make(map[columnName]string)
 
 		for i, col := range columns {
-			if values[i] == nil {
-				entry[col] = ""
-			} else {
-				entry[col] = string(values[i].([]byte))
-			}
+			entry[col] = valueToString(values[i])
 		}
 
 		tableData = append(tableData, entry)
@@ -255,76 +1034,154 @@ func rows2data(rows *sql.Rows) (result []map[string]string, err error) {
 	return tableData, nil
 }
 
-// Get a set of values in JSON format
-func getJSON(config *dbConn, key string) (result interface{}, err error) {
+// Get a set of values in JSON format, the number of rows returned by the query is reported too.
+// Variables and discovered objects are limited to names selected by the filter.
+func (p *Plugin) getJSON(ctx context.Context, config *dbConn, key, query string, lld bool, names nameFilter, args ...interface{}) (result interface{}, rows int, err error) {
+	// Rows are scanned with column types, so their values are represented according to the options.
+	if !lld && key != "mysql.get_status_variables" && key != "mysql.get_variables" {
+		return p.getTypedJSON(ctx, config, key, query, args...)
+	}
 
-	rows, err := config.connection.Query(keys[key].query)
+	tableData, err := queryContext(ctx, config, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	defer rows.Close()
 
-	tableData, err := rows2data(rows)
-	if err != nil {
-		return nil, err
+	if isReplicaStatus(key) {
+		legacyRows(tableData)
+	}
+
+	if err = p.checkRows(len(tableData)); err != nil {
+		return nil, 0, err
 	}
 
+	_, marshalSpan := startSpan(ctx, "marshal")
+	defer func() { marshalSpan.end(err) }()
+
 	var jsonData []byte
 	switch key {
-	case "mysql.get_status_variables":
+	case "mysql.get_status_variables", "mysql.get_variables":
 		{
 			m := make(map[string]string)
 			for _, j := range tableData {
 				m[j["Variable_name"]] = j["Value"]
 			}
 
-			jsonData, err = json.Marshal(m)
+			jsonData, err = json.Marshal(p.filterFields(key, matchNames(m, names)))
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 		}
-	case "mysql.replication.discovery":
+	case "mysql.db.discovery", "mysql.table.discovery", "mysql.partition.discovery":
 		{
 			m := make([]map[string]string, 0)
 			for _, j := range tableData {
-				m = append(m, map[string]string{"Master_Host": j["Master_Host"]})
+				if names.match(j[discoveredNames[key]]) {
+					m = append(m, j)
+				}
 			}
 
-			jsonData, err = json.Marshal(m)
+			jsonData, err = json.Marshal(p.applyMacros(key, p.filterFields(key, m)))
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 		}
-	case "mysql.replication.get_slave_status":
+	case "mysql.replication.discovery":
 		{
-			if len(tableData) == 0 {
-				return nil, errorNoReplication
+			m := make([]map[string]string, 0)
+			for _, j := range tableData {
+				// MariaDB has the name of a channel in Connection_name.
+				channel, ok := j["Channel_Name"]
+				if !ok {
+					channel = j["Connection_name"]
+				}
+				m = append(m, map[string]string{"Master_Host": j["Master_Host"], "Channel_Name": channel})
 			}
-			jsonData, err = json.Marshal(tableData[0])
+
+			jsonData, err = json.Marshal(p.applyMacros(key, p.filterFields(key, m)))
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 		}
 	default:
 		{
-			jsonData, err = json.Marshal(tableData)
+			jsonData, err = json.Marshal(p.applyMacros(key, p.filterFields(key, tableData)))
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 		}
 	}
 
-	return string(jsonData), nil
+	if err = p.checkSize(jsonData); err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(tableData), nil
 }
 
 // init registers metrics.
 func init() {
 	plugin.RegisterMetrics(&impl, "Mysql",
 		"mysql.get_status_variables", "Values of global status variables.",
+		"mysql.get_variables", "Values of global system variables.",
+		"mysql.status_variable", "Value of a global status variable.",
+		"mysql.variable", "Value of a global system variable.",
+		"mysql.read_only", "Read-only state of the server.",
 		"mysql.ping", "If the DBMS responds it returns '1', and '0' otherwise.",
 		"mysql.version", "MySQL version.",
+		"mysql.flavor", "Flavor of the server.",
 		"mysql.db.discovery", "Databases discovery.",
 		"mysql.db.size", "Database size in bytes.",
+		"mysql.db.sizes", "Sizes of all databases in bytes.",
+		"mysql.table.discovery", "Tables discovery.",
+		"mysql.table.fragmentation", "Free space of tables.",
+		"mysql.partition.discovery", "Partitions discovery.",
+		"mysql.partition.size", "Rows and size of a partition.",
+		"mysql.engines", "Storage engines discovery.",
+		"mysql.charsets", "Default character sets and collations.",
+		"mysql.galera.status", "Galera status.",
+		"mysql.galera.ready", "Galera node readiness.",
+		"mysql.galera.cluster_status", "Galera cluster component status.",
+		"mysql.galera.local_state", "Galera node state.",
+		"mysql.galera.cluster_size", "Galera cluster size.",
+		"mysql.galera.primary", "Galera quorum.",
+		"mysql.galera.flow", "Galera flow control.",
+		"mysql.gtid", "GTID status.",
+		"mysql.gtid.errant", "Errant transactions of a replica.",
+		"mysql.auto_increment", "Usage of AUTO_INCREMENT columns.",
+		"mysql.index.unused", "Unused indexes.",
+		"mysql.index.redundant", "Redundant indexes.",
+		"mysql.index.stats", "I/O statistics of indexes.",
 		"mysql.replication.discovery", "Replication discovery.",
-		"mysql.replication.get_slave_status", "Replication status.")
+		"mysql.binlog.size", "Binary logs size.",
+		"mysql.binlog.retention", "Binary logs retention.",
+		"mysql.group_replication.member", "Group Replication member status.",
+		"mysql.group_replication.flow_control", "Group Replication flow control.",
+		"mysql.innodb_cluster", "InnoDB Cluster metadata.",
+		"mysql.master_status", "Binary log status.",
+		"mysql.replication.errors", "Classified replication errors.",
+		"mysql.replication.get_replica_status", "Replication status.",
+		"mysql.replication.heartbeat", "Replication delay by pt-heartbeat in seconds.",
+		"mysql.replication.lag", "Replication lag in seconds.",
+		"mysql.replication.relay_log_space", "Relay logs size.",
+		"mysql.replication.relay_log_files", "Relay logs count.",
+		"mysql.replication.workers", "Replication applier workers.",
+		"mysql.replication.get_slave_status", "Replication status (deprecated).",
+		"mysql.roles", "Roles granted to accounts.",
+		"mysql.plugins.list", "Installed plugins and components.",
+		"mysql.plugins.discovery", "Server plugins discovery.",
+		"mysql.slow_log.stats", "Slow log aggregates since the last poll.",
+		"mysql.slow_log.entries", "Slow log entries since the last poll.",
+		"mysql.general_log.stats", "General log aggregates since the last poll.",
+		"mysql.admin.health", "State of the classic and admin ports.",
+		"mysql.service.state", "State of the Windows service of a local server.",
+		"mysql.diagnostics", "Diagnostic bundle for support requests.",
+		"mysql.ports.probe", "Reachability and latency of auxiliary ports.",
+		"mysql.plugin.connections", "Connections managed by the plugin.",
+		"mysql.plugin.stats", "Counters of the plugin itself.",
+		"mysql.plugin.pool", "Statistics of connection pools.",
+		"mysql.plugin.latency", "Query durations of a key.",
+		"mysql.plugin.querylog", "Time-limited logging of SQL statements.",
+		"mysql.keys", "Metadata of supported keys.",
+		"mysql.template", "Zabbix template for the server.")
 }
@@ -0,0 +1,140 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// matchPlaceholder is replaced with the first parameter of a metric in settings of a session matched by a pattern.
+const matchPlaceholder = "{MATCH}"
+
+// sessionPattern is a compiled pattern of a session template.
+type sessionPattern struct {
+	glob    string
+	re      *regexp.Regexp
+	session *Session
+}
+
+// isTemplate returns true if a session is matched by a pattern instead of its name.
+func (s *Session) isTemplate() bool {
+	return len(s.Match) > 0 || len(s.MatchRegexp) > 0
+}
+
+// matchValue is the form of a matched value substituted for the placeholder, a host label without characters
+// which could change the meaning of the URI, e.g. db@attacker:3306#.
+var matchValue = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// instantiate returns a copy of a session template with the placeholder replaced by a matched value.
+// The password is never substituted, so it cannot be sent to a host chosen by the value.
+func (s *Session) instantiate(value string) (*Session, error) {
+	if !matchValue.MatchString(value) {
+		return nil, errorPatternValue
+	}
+
+	session := *s
+	session.Uri = strings.Replace(s.Uri, matchPlaceholder, value, -1)
+	session.User = strings.Replace(s.User, matchPlaceholder, value, -1)
+	session.Match = ""
+	session.MatchRegexp = ""
+
+	return &session, nil
+}
+
+// newSessionPattern compiles the pattern of a session template.
+func newSessionPattern(s *Session) (*sessionPattern, error) {
+	if len(s.Match) > 0 && len(s.MatchRegexp) > 0 {
+		return nil, errorPatternBoth
+	}
+
+	pattern := &sessionPattern{glob: s.Match, session: s}
+
+	if len(s.Match) > 0 {
+		if _, err := path.Match(s.Match, ""); err != nil {
+			return nil, err
+		}
+	} else {
+		re, err := regexp.Compile(s.MatchRegexp)
+		if err != nil {
+			return nil, err
+		}
+		pattern.re = re
+	}
+
+	return pattern, nil
+}
+
+// match returns true if a given value matches the pattern.
+func (p *sessionPattern) match(value string) bool {
+	if p.re != nil {
+		return p.re.MatchString(value)
+	}
+
+	ok, _ := path.Match(p.glob, value)
+
+	return ok
+}
+
+// compilePatterns returns compiled patterns of all session templates sorted by session names.
+func compilePatterns(sessions map[string]*Session) []*sessionPattern {
+	names := make([]string, 0)
+	for name, s := range sessions {
+		if s.isTemplate() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	patterns := make([]*sessionPattern, 0, len(names))
+	for _, name := range names {
+		pattern, err := newSessionPattern(sessions[name])
+		if err != nil {
+			impl.Errf("cannot use the pattern of the session %s: %s", name, redactError(err))
+			continue
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+// findSession returns a configured session or an inventory session with a given name,
+// or a session template matching it.
+func (p *Plugin) findSession(name string) (*Session, bool, error) {
+	if s, ok := p.options.Sessions[name]; ok && !s.isTemplate() {
+		return s, true, nil
+	}
+
+	if s, ok := p.inventory[name]; ok {
+		return s, true, nil
+	}
+
+	for _, pattern := range p.patterns {
+		if pattern.match(name) {
+			s, err := pattern.session.instantiate(name)
+			return s, err == nil, err
+		}
+	}
+
+	return nil, false, nil
+}
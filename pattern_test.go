@@ -0,0 +1,55 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "testing"
+
+func TestInstantiate(t *testing.T) {
+	template := &Session{Uri: "tcp://{MATCH}.db.example.com:3306", User: "zbx_{MATCH}", Password: "{MATCH}", Match: "prod-*"}
+
+	tests := []struct {
+		value string
+		uri   string
+		user  string
+		err   error
+	}{
+		{"prod-1", "tcp://prod-1.db.example.com:3306", "zbx_prod-1", nil},
+		{"prod-eu_2.a", "tcp://prod-eu_2.a.db.example.com:3306", "zbx_prod-eu_2.a", nil},
+		{"prod-x@evil.example:3306#", "", "", errorPatternValue},
+		{"prod-x/evil", "", "", errorPatternValue},
+		{"prod-x?tls=false", "", "", errorPatternValue},
+		{"prod-%40x", "", "", errorPatternValue},
+		{"", "", "", errorPatternValue},
+	}
+
+	for _, tt := range tests {
+		s, err := template.instantiate(tt.value)
+		if err != tt.err {
+			t.Errorf("instantiate(%q) error = %v, expected %v", tt.value, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if s.Uri != tt.uri || s.User != tt.user || s.Password != template.Password || s.isTemplate() {
+			t.Errorf("instantiate(%q) = %+v", tt.value, s)
+		}
+	}
+}
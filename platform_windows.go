@@ -0,0 +1,88 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Networks available on the platform, Unix-sockets are not supported by MySQL on Windows.
+const (
+	unixSupported = false
+	pipeSupported = true
+)
+
+// dialPipe connects to a local named pipe, the address is the name of the pipe.
+func dialPipe(ctx context.Context, addr string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, `\\.\pipe\`+addr)
+}
+
+// queryServiceState returns the state of a service from the service control manager.
+func queryServiceState(name string) (interface{}, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		if err == windows.ERROR_SERVICE_DOES_NOT_EXIST {
+			return serviceNotFound, nil
+		}
+		return nil, err
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	switch status.State {
+	case svc.Running:
+		return serviceRunning, nil
+	case svc.Paused:
+		return servicePaused, nil
+	case svc.StartPending:
+		return serviceStartPending, nil
+	case svc.PausePending:
+		return servicePausePending, nil
+	case svc.ContinuePending:
+		return serviceContinuePending, nil
+	case svc.StopPending:
+		return serviceStopPending, nil
+	case svc.Stopped:
+		return serviceStopped, nil
+	}
+
+	return serviceUnknown, nil
+}
+
+func init() {
+	mysql.RegisterDialContext(pipeNet, dialPipe)
+}
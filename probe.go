@@ -0,0 +1,143 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type probeResult struct {
+	Address   string  `json:"address"`
+	Reachable int     `json:"reachable"`
+	Latency   float64 `json:"latency"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// probeAddresses returns addresses to probe for a session.
+// Bare ports are completed with the host of the session URI.
+func probeAddresses(s *Session) ([]string, error) {
+	ports := splitList(s.ProbePorts)
+	if len(ports) == 0 {
+		return nil, errorProbeNoPorts
+	}
+
+	var host string
+	if sessionURL, err := checkURI(s); err == nil && sessionURL.Scheme == "tcp" {
+		host = sessionURL.Hostname()
+	}
+
+	addresses := make([]string, 0, len(ports))
+
+	for _, port := range ports {
+		if _, err := strconv.ParseUint(port, 10, 16); err == nil {
+			if len(host) == 0 {
+				return nil, errorProbeHost
+			}
+			port = net.JoinHostPort(host, port)
+		} else if _, _, err = net.SplitHostPort(port); err != nil {
+			return nil, errorProbePort
+		}
+
+		addresses = append(addresses, port)
+	}
+
+	return addresses, nil
+}
+
+// sessionDial returns the dial function of the SSH tunnel or the proxy of a session, a direct TCP one otherwise.
+func sessionDial(s *Session) (mysql.DialContextFunc, error) {
+	var network string
+	var err error
+
+	if len(s.SSHHost) > 0 {
+		network, err = tunnels.network(s)
+	} else if len(s.Proxy) > 0 {
+		network, err = proxies.network(s.Proxy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if dial, ok := lookupDial(network); ok {
+		return dial, nil
+	}
+
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return newDialer().DialContext(ctx, "tcp", addr)
+	}, nil
+}
+
+// probePorts tries to open a TCP connection to each address concurrently and reports reachability and latency.
+// Connections go through the SSH tunnel or the proxy of the session like connections to the server.
+func (p *Plugin) probePorts(s *Session) (result interface{}, err error) {
+	addresses, err := probeAddresses(s)
+	if err != nil {
+		return nil, err
+	}
+
+	dial, err := sessionDial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout())
+	defer cancel()
+
+	results := make([]probeResult, len(addresses))
+
+	var wg sync.WaitGroup
+
+	for i, addr := range addresses {
+		wg.Add(1)
+
+		go func(r *probeResult, addr string) {
+			defer wg.Done()
+
+			r.Address = addr
+			start := time.Now()
+
+			conn, err := dial(ctx, addr)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			conn.Close()
+
+			r.Reachable = 1
+			r.Latency = time.Since(start).Seconds()
+		}(&results[i], addr)
+	}
+
+	wg.Wait()
+
+	jsonData, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,112 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/proxy"
+)
+
+// socksProxies stores names of the networks registered in the driver for each proxy URI.
+type socksProxies struct {
+	sync.Mutex
+	networks map[string]string
+}
+
+var proxies = socksProxies{networks: make(map[string]string)}
+
+// parseProxy parses a proxy URI like socks5://[user:password@]host:port
+func parseProxy(uri string) (*url.URL, error) {
+	proxyURL, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL.Scheme != "socks5" || len(proxyURL.Host) == 0 {
+		return nil, errorProxyURI
+	}
+
+	return proxyURL, nil
+}
+
+// checkProxy returns an error if the proxy of a session is malformed or combined with an SSH tunnel.
+func checkProxy(s *Session) error {
+	if len(s.Proxy) == 0 {
+		return nil
+	}
+
+	if len(s.SSHHost) > 0 {
+		return errorProxySSH
+	}
+
+	_, err := parseProxy(s.Proxy)
+
+	return err
+}
+
+// network returns the name of the driver network dialing through a given SOCKS5 proxy.
+// The network is registered on the first use.
+func (p *socksProxies) network(uri string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if network, ok := p.networks[uri]; ok {
+		return network, nil
+	}
+
+	proxyURL, err := parseProxy(uri)
+	if err != nil {
+		return "", err
+	}
+
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+
+	// The dialer is built for each connection, so it follows the connect budget of the current configuration.
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, newDialer())
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := dialer.(proxy.ContextDialer); ok {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+
+		return dialer.Dial("tcp", addr)
+	}
+
+	// The name must be a plain word because it is a part of DSN.
+	network := "socks" + strconv.Itoa(len(p.networks)+1)
+	registerDial(network, dial)
+
+	p.networks[uri] = network
+	impl.Debugf("Registered SOCKS5 proxy %s", proxyURL.Host)
+
+	return network, nil
+}
@@ -0,0 +1,93 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// queryLogger logs every SQL statement until the end of a time window. The window is stored in nanoseconds
+// and accessed atomically, zero means the log is disabled.
+type queryLogger struct {
+	until int64
+}
+
+var queryLog queryLogger
+
+// enable starts a window of a given length, a zero length disables the log.
+func (l *queryLogger) enable(window time.Duration) {
+	if window == 0 {
+		atomic.StoreInt64(&l.until, 0)
+		return
+	}
+
+	atomic.StoreInt64(&l.until, time.Now().Add(window).UnixNano())
+}
+
+// remaining returns the time left until the log is disabled.
+func (l *queryLogger) remaining() time.Duration {
+	if left := time.Until(time.Unix(0, atomic.LoadInt64(&l.until))); left > 0 {
+		return left
+	}
+
+	return 0
+}
+
+// record logs a statement run on a connection if the window is open.
+func (l *queryLogger) record(conn *dbConn, query string, start time.Time, rows int, err error) {
+	if l.remaining() == 0 {
+		return
+	}
+
+	if err != nil {
+		impl.Infof("SQL on %s: %s; %s, error: %s", conn.label(), query, time.Since(start), err.Error())
+		return
+	}
+
+	impl.Infof("SQL on %s: %s; %s, %d rows", conn.label(), query, time.Since(start), rows)
+}
+
+// setQueryLog enables the query log for a given number of seconds limited by QueryLogWindow, zero disables it.
+// Without a parameter the state is not changed. The number of seconds left is returned.
+func (p *Plugin) setQueryLog(params []string) (result interface{}, err error) {
+	if len(params) > 0 && len(params[0]) > 0 {
+		seconds, err := strconv.ParseUint(params[0], 10, 32)
+		if err != nil {
+			return nil, errorQueryLogWindow
+		}
+
+		window := time.Duration(seconds) * time.Second
+		if limit := time.Duration(p.options.QueryLogWindow) * time.Second; window > limit {
+			window = limit
+		}
+
+		queryLog.enable(window)
+
+		if window > 0 {
+			p.Infof("SQL statements will be logged for %s", window)
+		} else {
+			p.Infof("SQL statements logging is disabled")
+		}
+	}
+
+	return int64(queryLog.remaining().Seconds()), nil
+}
@@ -0,0 +1,373 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// replicaStatusVariants use SHOW REPLICA STATUS on MySQL 8.0.22 and later, SHOW SLAVE STATUS is removed in MySQL 8.4.
+var replicaStatusVariants = []queryVariant{
+	{family: familyMySQL, since: 80022, query: "show replica status"},
+}
+
+// replicaDiscoveryVariants are replicaStatusVariants listing every connection of a multi-source replica on MariaDB.
+var replicaDiscoveryVariants = []queryVariant{
+	replicaStatusVariants[0],
+	{family: familyMariaDB, query: "show all slaves status"},
+}
+
+// workersQuery returns applier workers of a multi-threaded replica. The lag and the retries of a worker are known
+// since MySQL 8.0.13 only, the lag is the time since the original commit of the transaction being applied.
+const workersQuery = `select channel_name as channel, worker_id, thread_id, service_state,
+	last_error_number, last_error_message, last_error_timestamp, null as lag, null as transactions_retried
+	from performance_schema.replication_applier_status_by_worker order by channel_name, worker_id`
+
+var workersVariants = []queryVariant{
+	{family: familyMySQL, since: 80013, query: `select channel_name as channel, worker_id, thread_id, service_state,
+	last_error_number, last_error_message, last_error_timestamp,
+	if(applying_transaction = '', 0,
+		greatest(0, timestampdiff(microsecond, applying_transaction_original_commit_timestamp, now(6))) / 1000000) as lag,
+	last_applied_transaction_retries_count + applying_transaction_retries_count as transactions_retried
+	from performance_schema.replication_applier_status_by_worker order by channel_name, worker_id`},
+}
+
+// binlogStatusVariants replace SHOW MASTER STATUS removed in MySQL 8.4 and deprecated in MariaDB 10.5.2.
+var binlogStatusVariants = []queryVariant{
+	{family: familyMySQL, since: 80200, query: "show binary log status"},
+	{family: familyMariaDB, since: 100502, query: "show binlog status"},
+}
+
+// legacyExceptions are columns of SHOW REPLICA STATUS not renamed word by word.
+var legacyExceptions = map[string]string{
+	"Get_Source_public_key": "Get_master_public_key",
+}
+
+// legacyColumn returns the name a column of SHOW REPLICA STATUS has in SHOW SLAVE STATUS,
+// e.g. Seconds_Behind_Master for Seconds_Behind_Source.
+func legacyColumn(name string) string {
+	if legacy, ok := legacyExceptions[name]; ok {
+		return legacy
+	}
+
+	words := strings.Split(name, "_")
+	for i, w := range words {
+		switch w {
+		case "Source":
+			words[i] = "Master"
+		case "Replica":
+			words[i] = "Slave"
+		}
+	}
+
+	return strings.Join(words, "_")
+}
+
+// stringEscaper escapes a string literal, the doubled quote does not depend on NO_BACKSLASH_ESCAPES.
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `''`)
+
+// forChannel limits a query of the replica status to a replication channel of a multi-source replica.
+// MariaDB names channels connections and selects one by SHOW SLAVE 'name' STATUS.
+func forChannel(ctx context.Context, conn *dbConn, query, channel string) (string, error) {
+	info, err := conn.serverVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if flavorFamily(info.flavor) == familyMariaDB {
+		return "show slave '" + stringEscaper.Replace(channel) + "' status", nil
+	}
+
+	return query + " for channel '" + stringEscaper.Replace(channel) + "'", nil
+}
+
+// isReplicaStatus checks that a metric returns rows of SHOW REPLICA STATUS or SHOW SLAVE STATUS.
+func isReplicaStatus(key string) bool {
+	return key == "mysql.replication.discovery" || key == "mysql.replication.get_replica_status"
+}
+
+// legacyRows renames columns of SHOW REPLICA STATUS to the names used by templates.
+func legacyRows(rows []map[string]string) {
+	for i, row := range rows {
+		legacy := make(map[string]string, len(row))
+		for name, value := range row {
+			legacy[legacyColumn(name)] = value
+		}
+		rows[i] = legacy
+	}
+}
+
+// legacyTypedRows is legacyRows for typed rows.
+func legacyTypedRows(rows []map[string]interface{}) {
+	for i, row := range rows {
+		legacy := make(map[string]interface{}, len(row))
+		for name, value := range row {
+			legacy[legacyColumn(name)] = value
+		}
+		rows[i] = legacy
+	}
+}
+
+// getReplicationLag returns Seconds_Behind_Master of the first row of the replica status, or null while it is NULL.
+// The lag is a float as null may be negative.
+func getReplicationLag(ctx context.Context, conn *dbConn, query string, null int) (interface{}, error) {
+	rows, err := queryContext(ctx, conn, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errorNoReplication
+	}
+
+	legacyRows(rows)
+
+	lag := rows[0]["Seconds_Behind_Master"]
+	if lag == "" {
+		return float64(null), nil
+	}
+
+	return strconv.ParseFloat(lag, 64)
+}
+
+// getRelayLogSpace returns Relay_Log_Space, the total size of the relay logs of the first row of the replica status.
+func getRelayLogSpace(ctx context.Context, conn *dbConn, query string) (interface{}, error) {
+	rows, err := queryContext(ctx, conn, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errorNoReplication
+	}
+
+	return strconv.ParseInt(rows[0]["Relay_Log_Space"], 10, 64)
+}
+
+// erExecutingCommand is the server error of SHOW RELAYLOG EVENTS for a relay log which is not in the index.
+const erExecutingCommand = 1220
+
+// maxRelayLogFiles is the number of relay logs at which getRelayLogFiles stops counting.
+const maxRelayLogFiles = 1000
+
+// relayLogEventsQuery returns a query reading the first event of a relay log of a channel, of the default one
+// if the channel is empty.
+func relayLogEventsQuery(info *serverInfo, file, channel string) string {
+	events := " events in '" + stringEscaper.Replace(file) + "' limit 1"
+
+	switch {
+	case channel == "":
+		return "show relaylog" + events
+	case flavorFamily(info.flavor) == familyMariaDB:
+		return "show relaylog '" + stringEscaper.Replace(channel) + "'" + events
+	}
+
+	return "show relaylog" + events + " for channel '" + stringEscaper.Replace(channel) + "'"
+}
+
+// getRelayLogFiles returns the number of relay logs of the first row of the replica status. They are not listed
+// by the server, but they are numbered one after another and purged from the oldest, so the logs are probed from
+// Relay_Log_File back to the first one missing in the index.
+func getRelayLogFiles(ctx context.Context, conn *dbConn, query, channel string) (interface{}, error) {
+	rows, err := queryContext(ctx, conn, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 || rows[0]["Relay_Log_File"] == "" {
+		return nil, errorNoReplication
+	}
+
+	file := rows[0]["Relay_Log_File"]
+	dot := strings.LastIndex(file, ".")
+	number, err := strconv.Atoi(file[dot+1:])
+	if dot < 0 || err != nil {
+		return nil, errorRelayLogName
+	}
+
+	info, err := conn.serverVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	for n := number; n > 0 && count < maxRelayLogFiles; n-- {
+		name := fmt.Sprintf("%s.%0*d", file[:dot], len(file)-dot-1, n)
+
+		_, err = queryContext(ctx, conn, relayLogEventsQuery(info, name, channel))
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == erExecutingCommand {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// identifierEscaper escapes a quoted identifier.
+var identifierEscaper = strings.NewReplacer("`", "``")
+
+func quoteIdentifier(name string) string {
+	return "`" + identifierEscaper.Replace(name) + "`"
+}
+
+// heartbeatQuery measures the time since the last heartbeat written by pt-heartbeat on a source.
+// Without a server_id the latest heartbeat of any source is used.
+const heartbeatQuery = `select greatest(0, timestampdiff(microsecond, max(ts), utc_timestamp(6))) / 1000000 as delay from %s.%s`
+
+// getHeartbeatDelay returns the replication delay in seconds measured by a pt-heartbeat table.
+func getHeartbeatDelay(ctx context.Context, conn *dbConn, schema, table, serverID string) (interface{}, error) {
+	query := fmt.Sprintf(heartbeatQuery, quoteIdentifier(schema), quoteIdentifier(table))
+
+	var args []interface{}
+	if serverID != "" {
+		query += " where server_id = ?"
+		args = append(args, serverID)
+	}
+
+	rows, err := queryContext(ctx, conn, query, args...)
+	if err != nil {
+		if e, ok := err.(*mysql.MySQLError); ok && e.Number == erNoSuchTable {
+			return nil, errorNoHeartbeatTable
+		}
+		return nil, err
+	}
+
+	if len(rows) == 0 || rows[0]["delay"] == "" {
+		return nil, errorNoHeartbeat
+	}
+
+	return strconv.ParseFloat(rows[0]["delay"], 64)
+}
+
+// Classes of replication errors.
+const (
+	replicationErrorNone       = "none"
+	replicationErrorConnection = "connection"
+	replicationErrorDuplicate  = "duplicate_key"
+	replicationErrorMissing    = "missing_row"
+	replicationErrorCorruption = "corruption"
+	replicationErrorOther      = "other"
+)
+
+// Severities of replication errors: the replica retries connection errors itself, the others need a DBA.
+const (
+	severityNone = iota
+	severityTransient
+	severityPermanent
+)
+
+// replicationErrorClasses maps server error numbers to classes, other numbers are of replicationErrorOther.
+var replicationErrorClasses = map[int]string{
+	1040: replicationErrorConnection, // ER_CON_COUNT_ERROR
+	1045: replicationErrorConnection, // ER_ACCESS_DENIED_ERROR
+	1129: replicationErrorConnection, // ER_HOST_IS_BLOCKED
+	1158: replicationErrorConnection, // ER_NET_READ_ERROR
+	1159: replicationErrorConnection, // ER_NET_READ_INTERRUPTED
+	1160: replicationErrorConnection, // ER_NET_ERROR_ON_WRITE
+	1161: replicationErrorConnection, // ER_NET_WRITE_INTERRUPTED
+	2003: replicationErrorConnection, // CR_CONN_HOST_ERROR
+	2005: replicationErrorConnection, // CR_UNKNOWN_HOST
+	2006: replicationErrorConnection, // CR_SERVER_GONE_ERROR
+	2013: replicationErrorConnection, // CR_SERVER_LOST
+	2026: replicationErrorConnection, // CR_SSL_CONNECTION_ERROR
+	1062: replicationErrorDuplicate,  // ER_DUP_ENTRY
+	1032: replicationErrorMissing,    // ER_KEY_NOT_FOUND, the row to update or delete is missing
+	1236: replicationErrorCorruption, // ER_MASTER_FATAL_ERROR_READING_BINLOG
+	1593: replicationErrorCorruption, // ER_SLAVE_FATAL_ERROR
+	1594: replicationErrorCorruption, // ER_SLAVE_RELAY_LOG_READ_FAILURE
+	1595: replicationErrorCorruption, // ER_SLAVE_RELAY_LOG_WRITE_FAILURE
+}
+
+// classifyReplicationError returns the class and the severity of a replication error number.
+func classifyReplicationError(errno int) (string, int) {
+	if errno == 0 {
+		return replicationErrorNone, severityNone
+	}
+
+	class, ok := replicationErrorClasses[errno]
+	if !ok {
+		class = replicationErrorOther
+	}
+
+	if class == replicationErrorConnection {
+		return class, severityTransient
+	}
+
+	return class, severityPermanent
+}
+
+// replicationErrors is the result of mysql.replication.errors, the severity is the highest of both threads.
+type replicationErrors struct {
+	IOErrno  int    `json:"Last_IO_Errno"`
+	IOError  string `json:"Last_IO_Error"`
+	IOClass  string `json:"io_class"`
+	SQLErrno int    `json:"Last_SQL_Errno"`
+	SQLError string `json:"Last_SQL_Error"`
+	SQLClass string `json:"sql_class"`
+	Severity int    `json:"severity"`
+}
+
+// getReplicationErrors returns the last errors of the replication threads of the first row of the replica status.
+func getReplicationErrors(ctx context.Context, conn *dbConn, query string) (interface{}, error) {
+	rows, err := queryContext(ctx, conn, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errorNoReplication
+	}
+
+	legacyRows(rows)
+
+	result := replicationErrors{IOError: rows[0]["Last_IO_Error"], SQLError: rows[0]["Last_SQL_Error"]}
+	if result.IOErrno, err = strconv.Atoi(rows[0]["Last_IO_Errno"]); err != nil {
+		return nil, err
+	}
+	if result.SQLErrno, err = strconv.Atoi(rows[0]["Last_SQL_Errno"]); err != nil {
+		return nil, err
+	}
+
+	var ioSeverity, sqlSeverity int
+	result.IOClass, ioSeverity = classifyReplicationError(result.IOErrno)
+	result.SQLClass, sqlSeverity = classifyReplicationError(result.SQLErrno)
+
+	result.Severity = ioSeverity
+	if sqlSeverity > result.Severity {
+		result.Severity = sqlSeverity
+	}
+
+	jsonData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,46 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import "testing"
+
+func TestClassifyReplicationError(t *testing.T) {
+	tests := []struct {
+		errno    int
+		class    string
+		severity int
+	}{
+		{0, replicationErrorNone, severityNone},
+		{1045, replicationErrorConnection, severityTransient},
+		{2003, replicationErrorConnection, severityTransient},
+		{2013, replicationErrorConnection, severityTransient},
+		{1062, replicationErrorDuplicate, severityPermanent},
+		{1032, replicationErrorMissing, severityPermanent},
+		{1236, replicationErrorCorruption, severityPermanent},
+		{1594, replicationErrorCorruption, severityPermanent},
+		{1146, replicationErrorOther, severityPermanent},
+	}
+
+	for _, tt := range tests {
+		if class, severity := classifyReplicationError(tt.errno); class != tt.class || severity != tt.severity {
+			t.Errorf("classifyReplicationError(%d) = %s, %d, expected %s, %d", tt.errno, class, severity, tt.class, tt.severity)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Server errors of read queries which are likely to pass on retry.
+const (
+	erLockWaitTimeout = 1205
+	erLockDeadlock    = 1213
+)
+
+// isTransient reports whether an error is caused by a dropped connection or a lock conflict.
+func isTransient(err error) bool {
+	if err == driver.ErrBadConn || err == mysql.ErrInvalidConn {
+		return true
+	}
+
+	if e, ok := err.(*mysql.MySQLError); ok {
+		return e.Number == erLockWaitTimeout || e.Number == erLockDeadlock
+	}
+
+	msg := err.Error()
+
+	return strings.Contains(msg, "invalid connection") || strings.Contains(msg, "bad connection")
+}
+
+// withRetry runs a query again after transient errors, the delay doubles with each attempt.
+// Retries stop when the context of the query phase expires.
+func (p *Plugin) withRetry(ctx context.Context, query func() (interface{}, error)) (result interface{}, err error) {
+	delay := time.Duration(p.options.RetryDelay) * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		result, err = query()
+		if err == nil || attempt > p.options.RetryCount || !isTransient(err) {
+			return
+		}
+
+		p.Debugf("Retrying the query after a transient error (attempt %d): %s", attempt, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
@@ -0,0 +1,151 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Prefixes of secret references.
+const (
+	secretEnv   = "env:"
+	secretFile  = "file:"
+	secretVault = "vault:"
+)
+
+// vaultTimeout is the maximum time for reading a secret from Vault.
+const vaultTimeout = 10 * time.Second
+
+// isSecretRef returns true if a value is a secret reference rather than a literal secret.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretEnv) || strings.HasPrefix(value, secretFile) ||
+		strings.HasPrefix(value, secretVault)
+}
+
+// readVaultSecret reads a field of a secret from HashiCorp Vault given as path#field, e.g. secret/data/mysql#password.
+// The address and the token are taken from the VAULT_ADDR and VAULT_TOKEN environment variables.
+// Both KV version 1 and version 2 secrets are supported.
+func readVaultSecret(ref string) (string, error) {
+	parts := strings.SplitN(ref, "#", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", errorSecretVaultRef
+	}
+
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if len(addr) == 0 || len(token) == 0 {
+		return "", errorSecretVaultEnv
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+parts[0], nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	client := http.Client{Timeout: vaultTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errorSecretVaultStatus
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	value, ok := data[parts[1]].(string)
+	if !ok {
+		return "", errorSecretVaultField
+	}
+
+	return value, nil
+}
+
+// secretPassword returns a literal password or the value of a secret reference if it is given instead.
+// Literal passwords are never treated as references, so a password may start with env: or file: too.
+func secretPassword(password, ref string) (string, error) {
+	if len(ref) == 0 {
+		return password, nil
+	}
+
+	if len(password) > 0 {
+		return "", errorSecretBoth
+	}
+
+	return resolveSecret(ref)
+}
+
+// checkSecret validates a pair of a literal password and a secret reference.
+// Literal passwords are not allowed if references are required.
+func checkSecret(password, ref string, required bool) error {
+	if len(password) > 0 && len(ref) > 0 {
+		return errorSecretBoth
+	}
+
+	if len(ref) > 0 && !isSecretRef(ref) {
+		return errorSecretRef
+	}
+
+	if required && len(password) > 0 {
+		return errorSecretLiteral
+	}
+
+	return nil
+}
+
+// resolveSecret returns the value of a secret reference like env:NAME, file:/path/to/file or vault:path#field.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretVault):
+		return readVaultSecret(strings.TrimPrefix(value, secretVault))
+	case strings.HasPrefix(value, secretEnv):
+		secret, ok := os.LookupEnv(strings.TrimPrefix(value, secretEnv))
+		if !ok {
+			return "", errorSecretEnv
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFile):
+		secret, err := ioutil.ReadFile(strings.TrimPrefix(value, secretFile))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	}
+
+	return "", errorSecretRef
+}
@@ -0,0 +1,81 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"net"
+)
+
+// pipeNet is the name of the network registered in the driver for Windows named pipes.
+const pipeNet = "pipe"
+
+// defaultServiceName is the name of the Windows service used if a metric does not give one.
+const defaultServiceName = "MySQL"
+
+// States of a Windows service, the same as reported by service.info of the agent.
+const (
+	serviceRunning = iota
+	servicePaused
+	serviceStartPending
+	servicePausePending
+	serviceContinuePending
+	serviceStopPending
+	serviceStopped
+	serviceUnknown
+	serviceNotFound = 255
+)
+
+// isLocal checks that a session connects to a server on the same host, as only local services can be queried.
+func isLocal(s *Session) bool {
+	sessionURL, err := checkURI(s)
+	if err != nil {
+		return false
+	}
+
+	switch sessionURL.Scheme {
+	case pipeNet:
+		return true
+	case "tcp":
+		host := sessionURL.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+
+		return ip != nil && ip.IsLoopback()
+	}
+
+	return false
+}
+
+// getServiceState returns the state of the Windows service of a local server.
+// The service name is given as the fourth parameter.
+func getServiceState(s *Session, params []string) (interface{}, error) {
+	if !isLocal(s) {
+		return nil, errorServiceNotLocal
+	}
+
+	name := defaultServiceName
+	if len(params) > 3 && len(params[3]) > 0 {
+		name = params[3]
+	}
+
+	return queryServiceState(name)
+}
@@ -0,0 +1,211 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// defaultCollectPeriod is reported to the agent if the collection is disabled, as the period must be positive.
+const defaultCollectPeriod = 60
+
+// snapshot is a set of global status and variables of a session collected at once.
+type snapshot struct {
+	status    map[string]string
+	variables map[string]string
+	collected time.Time
+}
+
+// forKey returns status or system variables depending on the metric.
+func (s *snapshot) forKey(key string) map[string]string {
+	if key == "mysql.get_variables" || key == "mysql.variable" {
+		return s.variables
+	}
+
+	return s.status
+}
+
+// snapshotStore stores the last snapshot of each named session.
+type snapshotStore struct {
+	sync.RWMutex
+	entries map[string]*snapshot
+}
+
+var snapshots = snapshotStore{entries: make(map[string]*snapshot)}
+
+// get returns the snapshot of a session if it has been collected within maxAge.
+func (s *snapshotStore) get(session string, maxAge time.Duration) (*snapshot, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if snap, ok := s.entries[session]; ok && time.Since(snap.collected) <= maxAge {
+		return snap, true
+	}
+
+	return nil, false
+}
+
+func (s *snapshotStore) put(session string, snap *snapshot) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.entries[session] = snap
+}
+
+// clear removes all snapshots, e.g. when the configuration is reloaded.
+func (s *snapshotStore) clear() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.entries = make(map[string]*snapshot)
+}
+
+// collectJob is a session to be collected with its connection settings.
+type collectJob struct {
+	name           string
+	mysqlConf      *mysql.Config
+	initStatements []string
+	maxLifetime    time.Duration
+}
+
+// Period implements the Collector interface.
+func (p *Plugin) Period() int {
+	p.optionsMutex.RLock()
+	defer p.optionsMutex.RUnlock()
+
+	if p.options.CollectPeriod == 0 {
+		return defaultCollectPeriod
+	}
+
+	return p.options.CollectPeriod
+}
+
+// Collect implements the Collector interface.
+// It collects snapshots of all named sessions concurrently, failures are logged only.
+func (p *Plugin) Collect() error {
+	p.optionsMutex.RLock()
+
+	if p.options.CollectPeriod == 0 || p.connMgr == nil {
+		p.optionsMutex.RUnlock()
+		return nil
+	}
+
+	jobs := make([]collectJob, 0)
+	addJob := func(name string, session *Session) {
+		session = session.forKey("mysql.get_status_variables")
+
+		mysqlConf, err := p.getConfigDSN(session)
+		if err != nil {
+			p.Warningf("cannot collect the session %s: %s", name, redactError(err))
+			return
+		}
+
+		jobs = append(jobs, collectJob{
+			name:           name,
+			mysqlConf:      mysqlConf,
+			initStatements: splitStatements(session.InitStatements),
+			maxLifetime:    session.maxLifetime(),
+		})
+	}
+
+	for name, session := range p.options.Sessions {
+		if !session.isTemplate() {
+			addJob(name, session)
+		}
+	}
+
+	for name, session := range p.inventory {
+		if _, ok := p.options.Sessions[name]; !ok {
+			addJob(name, session)
+		}
+	}
+
+	timeout := p.budget.query
+	p.optionsMutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job collectJob) {
+			defer wg.Done()
+
+			if err := p.collectSession(job, timeout); err != nil {
+				p.Warningf("cannot collect the session %s: %s", job.name, redactError(err))
+			}
+		}(job)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// collectSession queries global status and variables of a session and stores them as its snapshot.
+func (p *Plugin) collectSession(job collectJob, timeout time.Duration) error {
+	conn, err := p.connMgr.GetConnection(ctx, job.mysqlConf, job.initStatements, job.maxLifetime, job.name)
+	if err != nil {
+		return err
+	}
+
+	queryCtx, queryCancel := context.WithTimeout(ctx, timeout)
+	defer queryCancel()
+
+	snap := &snapshot{collected: time.Now()}
+
+	if snap.status, err = queryVariables(queryCtx, conn, "show global status"); err != nil {
+		return err
+	}
+
+	if snap.variables, err = queryVariables(queryCtx, conn, "show global variables"); err != nil {
+		return err
+	}
+
+	snapshots.put(job.name, snap)
+	p.Debugf("Collected the session %s", job.name)
+
+	return nil
+}
+
+// queryVariables returns the result of a SHOW STATUS or SHOW VARIABLES statement as a map.
+func queryVariables(ctx context.Context, conn *dbConn, query string, args ...interface{}) (map[string]string, error) {
+	tableData, err := queryContext(ctx, conn, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string, len(tableData))
+	for _, row := range tableData {
+		m[row["Variable_name"]] = row["Value"]
+	}
+
+	return m, nil
+}
+
+// getSnapshot returns the snapshot of a named session if it has been collected within the last two periods.
+func (p *Plugin) getSnapshot(session string) (*snapshot, bool) {
+	if p.options.CollectPeriod == 0 {
+		return nil, false
+	}
+
+	return snapshots.get(session, 2*time.Duration(p.options.CollectPeriod)*time.Second)
+}
@@ -0,0 +1,65 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// srvNet is the name of the network registered in the driver for SRV based endpoints.
+const srvNet = "srv"
+
+// dialSRV resolves the SRV record given as addr and dials its targets in the order returned by the resolver.
+// The record is resolved on every dial, thus a new physical connection always follows the current placement
+// of a service.
+func dialSRV(ctx context.Context, addr string) (conn net.Conn, err error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errorSRVNoRecords
+	}
+
+	dialer := newDialer()
+
+	for _, r := range records {
+		target := net.JoinHostPort(r.Target, strconv.Itoa(int(r.Port)))
+
+		if conn, err = dialer.DialContext(ctx, "tcp", target); err == nil {
+			impl.Debugf("Resolved %s to %s", addr, target)
+			return conn, nil
+		}
+
+		impl.Debugf("Cannot connect to %s resolved from %s: %s", target, addr, err.Error())
+	}
+
+	// Return the last error only.
+	return nil, err
+}
+
+func init() {
+	mysql.RegisterDialContext(srvNet, dialSRV)
+}
@@ -0,0 +1,245 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshTunnel dials MySQL servers through a single SSH connection which is re-established after a failure.
+type sshTunnel struct {
+	sync.Mutex
+	host   string
+	config *ssh.ClientConfig
+	client *ssh.Client
+
+	// connecting is closed when the SSH connection being established by one of the callers is ready or has failed,
+	// connectErr is the error of that attempt.
+	connecting chan struct{}
+	connectErr error
+}
+
+// sshTunnels stores tunnels by their settings and names of the networks registered for them in the driver.
+type sshTunnels struct {
+	sync.Mutex
+	networks map[string]string
+	tunnels  map[string]*sshTunnel
+}
+
+var tunnels = sshTunnels{
+	networks: make(map[string]string),
+	tunnels:  make(map[string]*sshTunnel),
+}
+
+// checkSSH returns an error if SSH options of a session are incomplete.
+func checkSSH(s *Session) error {
+	if len(s.SSHHost) == 0 {
+		return nil
+	}
+
+	if len(s.SSHUser) == 0 || len(s.SSHKeyFile) == 0 || len(s.SSHKnownHosts) == 0 {
+		return errorSSHOptions
+	}
+
+	return nil
+}
+
+// dialSSH establishes an SSH connection within the deadline of a given context.
+func dialSSH(ctx context.Context, host string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := newDialer().DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake is bounded by the connect budget if the context has no deadline.
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout())
+	}
+	conn.SetDeadline(deadline)
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, host, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// sshClient returns the SSH connection of the tunnel establishing it if needed. The connection is established
+// without the lock by one caller only, the others wait for it until their contexts are done.
+func (t *sshTunnel) sshClient(ctx context.Context) (*ssh.Client, error) {
+	t.Lock()
+
+	if t.client != nil {
+		client := t.client
+		t.Unlock()
+		return client, nil
+	}
+
+	if t.connecting != nil {
+		wait := t.connecting
+		t.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		t.Lock()
+		defer t.Unlock()
+
+		if t.client == nil && t.connectErr == nil {
+			return nil, errorSSHClosed
+		}
+		return t.client, t.connectErr
+	}
+
+	wait := make(chan struct{})
+	t.connecting = wait
+	t.Unlock()
+
+	client, err := dialSSH(ctx, t.host, t.config)
+
+	t.Lock()
+	t.client, t.connectErr, t.connecting = client, err, nil
+	t.Unlock()
+	close(wait)
+
+	if err != nil {
+		return nil, err
+	}
+
+	impl.Debugf("Created new SSH tunnel: %s", t.host)
+
+	return client, nil
+}
+
+// drop closes a broken SSH connection unless it has been replaced already.
+func (t *sshTunnel) drop(client *ssh.Client) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.client == client {
+		t.client.Close()
+		t.client = nil
+	}
+}
+
+// dial opens a connection to addr through the SSH server. A broken SSH connection is established again once.
+func (t *sshTunnel) dial(ctx context.Context, addr string) (net.Conn, error) {
+	client, err := t.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.Dial("tcp", addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	impl.Debugf("SSH tunnel to %s is broken: %s", t.host, err.Error())
+	t.drop(client)
+
+	if client, err = t.sshClient(ctx); err != nil {
+		return nil, err
+	}
+
+	return client.Dial("tcp", addr)
+}
+
+// close closes the SSH connection of the tunnel if it is established.
+func (t *sshTunnel) close() {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+		impl.Debugf("Closed the SSH tunnel: %s", t.host)
+	}
+}
+
+// network returns the name of the driver network tunnelling connections with the SSH settings of a session.
+// The tunnel and its network are created on the first use.
+func (t *sshTunnels) network(s *Session) (string, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	id := s.SSHUser + "@" + s.SSHHost + " " + s.SSHKeyFile + " " + s.SSHKnownHosts
+
+	if network, ok := t.networks[id]; ok {
+		return network, nil
+	}
+
+	key, err := ioutil.ReadFile(s.SSHKeyFile)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	hostKeyCallback, err := knownhosts.New(s.SSHKnownHosts)
+	if err != nil {
+		return "", err
+	}
+
+	tunnel := &sshTunnel{
+		host: s.SSHHost,
+		config: &ssh.ClientConfig{
+			User:            s.SSHUser,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+		},
+	}
+
+	// The name must be a plain word because it is a part of DSN.
+	network := "ssh" + strconv.Itoa(len(t.networks)+1)
+	registerDial(network, tunnel.dial)
+
+	t.networks[id] = network
+	t.tunnels[network] = tunnel
+
+	return network, nil
+}
+
+// closeAll closes SSH connections of all tunnels. Tunnels stay registered and reconnect on demand.
+func (t *sshTunnels) closeAll() {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, tunnel := range t.tunnels {
+		tunnel.close()
+	}
+}
@@ -0,0 +1,144 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+type keyStats struct {
+	Exports         uint64  `json:"exports"`
+	Errors          uint64  `json:"errors"`
+	Queries         uint64  `json:"queries"`
+	AvgQueryLatency float64 `json:"avg_query_latency"`
+	queryTime       time.Duration
+	latency         latencyRing
+}
+
+// pluginStats stores counters maintained by the plugin itself.
+type pluginStats struct {
+	sync.Mutex
+	Keys               map[string]*keyStats    `json:"keys"`
+	ConnectionsCreated uint64                  `json:"connections_created"`
+	Reconnects         uint64                  `json:"reconnects"`
+	ConnectionsClosed  uint64                  `json:"connections_closed"`
+	ConnectionErrors   uint64                  `json:"connection_errors"`
+	Backends           map[string]*backendLoad `json:"backends"`
+	Deprecations       map[string]uint64       `json:"deprecations"`
+}
+
+var stats = pluginStats{
+	Keys:         make(map[string]*keyStats),
+	Backends:     make(map[string]*backendLoad),
+	Deprecations: make(map[string]uint64),
+}
+
+// key returns counters of a metric key creating them if needed. The caller must hold the lock.
+func (s *pluginStats) key(key string) *keyStats {
+	ks, ok := s.Keys[key]
+	if !ok {
+		ks = &keyStats{}
+		s.Keys[key] = ks
+	}
+
+	return ks
+}
+
+// addExport counts an export of a key and its error if any.
+func (s *pluginStats) addExport(key string, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	ks := s.key(key)
+	ks.Exports++
+
+	if err != nil {
+		ks.Errors++
+	}
+}
+
+// addQuery counts a query run for a key and its duration.
+func (s *pluginStats) addQuery(key string, duration time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	ks := s.key(key)
+	ks.Queries++
+	ks.queryTime += duration
+	ks.AvgQueryLatency = (ks.queryTime / time.Duration(ks.Queries)).Seconds()
+	ks.latency.add(duration)
+}
+
+// addConnection counts a new managed connection.
+func (s *pluginStats) addConnection() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.ConnectionsCreated++
+}
+
+// addReconnect counts a new physical connection made for an existing managed connection.
+func (s *pluginStats) addReconnect() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Reconnects++
+}
+
+// addClose counts a closed managed connection.
+func (s *pluginStats) addClose() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.ConnectionsClosed++
+}
+
+// addConnError counts a failed connection attempt or ping.
+func (s *pluginStats) addConnError() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.ConnectionErrors++
+}
+
+// addDeprecation counts a use of a deprecated name and returns the number of uses.
+func (s *pluginStats) addDeprecation(name string) uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Deprecations[name]++
+
+	return s.Deprecations[name]
+}
+
+// getStats returns the plugin's counters in JSON format.
+func getStats() (result interface{}, err error) {
+	stats.Lock()
+	defer stats.Unlock()
+
+	jsonData, err := json.Marshal(&stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,247 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	templateGroup    = "Templates/Databases"
+	templateApp      = "MySQL"
+	templateURIMacro = "{$MYSQL.URI}"
+)
+
+// lldMacroPaths maps LLD macros of discovery keys to JSONPath of the discovered values.
+var lldMacroPaths = map[string][][2]string{
+	"mysql.db.discovery":          {{"{#DATABASE}", "$.Database"}},
+	"mysql.replication.discovery": {{"{#MASTER_HOST}", "$.Master_Host"}, {"{#CHANNEL}", "$.Channel_Name"}},
+	"mysql.table.discovery":       {{"{#TABLE}", "$.Table"}, {"{#ENGINE}", "$.Engine"}, {"{#SCHEMA}", "$.Schema"}},
+	"mysql.partition.discovery":   {{"{#PARTITION}", "$.Partition"}, {"{#TABLE}", "$.Table"}, {"{#SCHEMA}", "$.Schema"}},
+	"mysql.engines":               {{"{#ENGINE}", "$.Engine"}, {"{#SUPPORT}", "$.Support"}},
+	"mysql.plugins.discovery":     {{"{#PLUGIN}", "$.Name"}, {"{#STATUS}", "$.Status"}, {"{#TYPE}", "$.Type"}},
+}
+
+// discoveryMacro returns the macro of the first discovered value of a discovery key used by item prototypes.
+func (p *Plugin) discoveryMacro(key string) string {
+	mp := lldMacroPaths[key][0]
+	if names, ok := p.macros[key]; ok {
+		return names.macro(strings.TrimPrefix(mp[1], "$."))
+	}
+
+	return mp[0]
+}
+
+type xmlName struct {
+	Name string `xml:"name"`
+}
+
+type xmlItem struct {
+	Name         string    `xml:"name"`
+	Key          string    `xml:"key"`
+	Trends       string    `xml:"trends,omitempty"`
+	ValueType    string    `xml:"value_type"`
+	Units        string    `xml:"units,omitempty"`
+	Description  string    `xml:"description"`
+	Applications []xmlName `xml:"applications>application"`
+}
+
+type xmlMacroPath struct {
+	Macro string `xml:"lld_macro"`
+	Path  string `xml:"path"`
+}
+
+type xmlDiscoveryRule struct {
+	Name           string         `xml:"name"`
+	Key            string         `xml:"key"`
+	Delay          string         `xml:"delay"`
+	Description    string         `xml:"description"`
+	ItemPrototypes []xmlItem      `xml:"item_prototypes>item_prototype"`
+	MacroPaths     []xmlMacroPath `xml:"lld_macro_paths>lld_macro_path"`
+}
+
+type xmlMacro struct {
+	Macro string `xml:"macro"`
+	Value string `xml:"value"`
+}
+
+type xmlTemplate struct {
+	Template       string             `xml:"template"`
+	Name           string             `xml:"name"`
+	Description    string             `xml:"description"`
+	Groups         []xmlName          `xml:"groups>group"`
+	Applications   []xmlName          `xml:"applications>application"`
+	Items          []xmlItem          `xml:"items>item"`
+	DiscoveryRules []xmlDiscoveryRule `xml:"discovery_rules>discovery_rule"`
+	Macros         []xmlMacro         `xml:"macros>macro"`
+}
+
+type xmlExport struct {
+	XMLName   xml.Name      `xml:"zabbix_export"`
+	Version   string        `xml:"version"`
+	Date      string        `xml:"date"`
+	Groups    []xmlName     `xml:"groups>group"`
+	Templates []xmlTemplate `xml:"templates>template"`
+}
+
+// templateValueType returns the name of a value type used in Zabbix templates.
+func templateValueType(k *key) string {
+	if k.json {
+		return "TEXT"
+	}
+
+	switch k.valueType {
+	case valueInt:
+		return "UNSIGNED"
+	case valueFloat:
+		return "FLOAT"
+	}
+
+	return "TEXT"
+}
+
+// templateItem builds an item of a metric, the macro is set as the parameter given by lldParam if the metric
+// is an item prototype.
+func templateItem(name string, k *key, macro string) xmlItem {
+	params := []string{templateURIMacro}
+	if len(macro) > 0 {
+		for len(params) < k.lldParam {
+			params = append(params, "")
+		}
+		params = append(params, macro)
+	}
+
+	item := xmlItem{
+		Name:         name,
+		Key:          fmt.Sprintf("%s[%s]", name, strings.Join(params, ",")),
+		ValueType:    templateValueType(k),
+		Units:        k.units,
+		Description:  k.summary,
+		Applications: []xmlName{{Name: templateApp}},
+	}
+
+	if item.ValueType == "TEXT" {
+		item.Trends = "0"
+	}
+
+	if len(macro) > 0 {
+		item.Name += " " + macro
+	}
+
+	return item
+}
+
+// getTemplate detects the server and returns a Zabbix template with the metrics it supports.
+func (p *Plugin) getTemplate(conn *dbConn, uri string) (result interface{}, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.budget.query)
+	defer cancel()
+
+	info, err := conn.serverInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(keys))
+	for name, k := range keys {
+		if !k.internal && info.has(k.requires) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	title := fmt.Sprintf("Template DB MySQL by Zabbix agent 2 (%s %s)", info.flavor, info.version)
+	tmpl := xmlTemplate{
+		Template:     title,
+		Name:         title,
+		Description:  "Generated by the MySQL plugin for " + info.flavor + " " + info.version + ".",
+		Groups:       []xmlName{{Name: templateGroup}},
+		Applications: []xmlName{{Name: templateApp}},
+		Macros:       []xmlMacro{{Macro: templateURIMacro, Value: uri}},
+	}
+
+	rules := make(map[string]*xmlDiscoveryRule)
+
+	for _, name := range names {
+		// Discovery of objects within a database needs the database as a parameter, so it is left out.
+		k := keys[name]
+		if !k.lld || k.minParams > 3 {
+			continue
+		}
+
+		rule := &xmlDiscoveryRule{
+			Name:        name,
+			Key:         fmt.Sprintf("%s[%s]", name, templateURIMacro),
+			Delay:       "1h",
+			Description: k.summary,
+		}
+		// Macros named by a macro rule are in the discovered objects already, so they need no paths.
+		if _, ok := p.macros[name]; !ok {
+			for _, mp := range lldMacroPaths[name] {
+				rule.MacroPaths = append(rule.MacroPaths, xmlMacroPath{Macro: mp[0], Path: mp[1]})
+			}
+		}
+		rules[name] = rule
+	}
+
+	for _, name := range names {
+		k := keys[name]
+
+		switch {
+		case k.lld:
+			continue
+		case len(k.discovery) > 0:
+			rule, ok := rules[k.discovery]
+			if !ok || len(lldMacroPaths[k.discovery]) == 0 {
+				continue
+			}
+			rule.ItemPrototypes = append(rule.ItemPrototypes, templateItem(name, &k, p.discoveryMacro(k.discovery)))
+		case k.minParams > 1:
+			// Keys needing more than the URI, e.g. the name of a variable, make no item on their own.
+			continue
+		default:
+			tmpl.Items = append(tmpl.Items, templateItem(name, &k, ""))
+		}
+	}
+
+	for _, name := range names {
+		if rule, ok := rules[name]; ok {
+			tmpl.DiscoveryRules = append(tmpl.DiscoveryRules, *rule)
+		}
+	}
+
+	export := xmlExport{
+		Version:   "4.4",
+		Date:      time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Groups:    []xmlName{{Name: templateGroup}},
+		Templates: []xmlTemplate{tmpl},
+	}
+
+	data, err := xml.MarshalIndent(&export, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	return xml.Header + string(data), nil
+}
@@ -0,0 +1,53 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTemplateItemPrototypes checks that keys of item prototypes pass the validation of parameters by Export.
+func TestTemplateItemPrototypes(t *testing.T) {
+	for name, k := range keys {
+		if len(k.discovery) == 0 {
+			continue
+		}
+
+		k := k
+		item := templateItem(name, &k, "{#OBJECT}")
+		params := strings.Split(strings.TrimSuffix(strings.TrimPrefix(item.Key, name+"["), "]"), ",")
+
+		if err := checkParams(name, params); err != nil {
+			t.Errorf("%s: checkParams() = %s", item.Key, err)
+			continue
+		}
+
+		if params[k.lldParam] != "{#OBJECT}" {
+			t.Errorf("%s: the macro is not the parameter %d", item.Key, k.lldParam)
+		}
+
+		if name == "mysql.db.size" {
+			if mode, err := sizeMode(params); err != nil || mode != sizeTotal {
+				t.Errorf("%s: sizeMode() = %q, %v, expected %q", item.Key, mode, err, sizeTotal)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Shares of the item timeout in tenths given to each phase of an export.
+const (
+	connectShare = 3
+	queryShare   = 7
+)
+
+// timeoutBudget splits the item timeout between the phases of an export. Marshaling of results has no phase,
+// JSON results are bounded by MaxRows and MaxResultBytes instead.
+type timeoutBudget struct {
+	connect time.Duration
+	query   time.Duration
+}
+
+// newTimeoutBudget derives the limits of all phases from the item timeout given in seconds.
+func newTimeoutBudget(timeout int) timeoutBudget {
+	tenth := time.Duration(timeout) * time.Second / 10
+
+	return timeoutBudget{
+		connect: connectShare * tenth,
+		query:   queryShare * tenth,
+	}
+}
+
+// connectTimeout is the connect phase budget in nanoseconds for dialers of the networks registered in the driver,
+// which run without the options lock. It is stored atomically as Configure changes it while exports are dialing.
+var connectTimeout int64
+
+// setConnectTimeout sets the connect phase budget of dialers.
+func setConnectTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&connectTimeout, int64(timeout))
+}
+
+// dialTimeout returns the connect phase budget of dialers.
+func dialTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&connectTimeout))
+}
+
+// phaseTimeoutError reports a phase of an export which exceeded its budget.
+type phaseTimeoutError struct {
+	phase  string
+	budget time.Duration
+}
+
+func (e phaseTimeoutError) Error() string {
+	return fmt.Sprintf("Timeout of the %s phase exceeded (%s)", e.phase, e.budget)
+}
+
+// checkQuery reports a timeout of the query phase and kills the query on the server if it is configured.
+func (p *Plugin) checkQuery(ctx context.Context, conn *dbConn, connID uint64, err error) error {
+	err = checkPhase(ctx, err, "query", p.budget.query)
+
+	if _, ok := err.(phaseTimeoutError); ok && p.options.KillOnTimeout == 1 && connID != 0 {
+		go p.connMgr.killQuery(conn, connID)
+	}
+
+	return err
+}
+
+// checkPhase replaces an error with phaseTimeoutError if the context of a phase has expired.
+func checkPhase(ctx context.Context, err error, phase string, budget time.Duration) error {
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return phaseTimeoutError{phase: phase, budget: budget}
+	}
+
+	return err
+}
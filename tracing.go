@@ -0,0 +1,266 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	traceService   = "zabbix-agent2-mysql"
+	traceBatchSize = 256
+	traceQueueSize = 4096
+	traceInterval  = 5 * time.Second
+)
+
+// OTLP span kinds and status codes.
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusOk         = 1
+	statusError      = 2
+)
+
+// span is a timed operation of an export sent to an OTLP/HTTP collector.
+type span struct {
+	traceID string
+	spanID  string
+	parent  string
+	name    string
+	kind    int
+	start   time.Time
+	attrs   map[string]string
+}
+
+type spanKey struct{}
+
+// spanExporter sends finished spans to an OTLP/HTTP endpoint in JSON encoding in batches.
+type spanExporter struct {
+	endpoint string
+	client   *http.Client
+	spans    chan otlpSpan
+}
+
+// tracer is the exporter of the running plugin, tracing is disabled if it is nil.
+var tracer *spanExporter
+
+func newSpanExporter(endpoint string) *spanExporter {
+	return &spanExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: traceInterval},
+		spans:    make(chan otlpSpan, traceQueueSize),
+	}
+}
+
+// randomID returns a random hex encoded id of a given number of bytes.
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// startTrace starts the root span of a new trace if tracing is enabled.
+func startTrace(ctx context.Context, name string, attrs ...string) (context.Context, *span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	s := &span{traceID: randomID(16), spanID: randomID(8), name: name, kind: spanKindInternal, start: time.Now()}
+	s.set(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// startSpan starts a child span of the span stored in a context. No span is started without a parent.
+func startSpan(ctx context.Context, name string, attrs ...string) (context.Context, *span) {
+	parent, ok := ctx.Value(spanKey{}).(*span)
+	if !ok || parent == nil {
+		return ctx, nil
+	}
+
+	s := &span{traceID: parent.traceID, spanID: randomID(8), parent: parent.spanID, name: name, kind: spanKindClient,
+		start: time.Now()}
+	s.set(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// set adds attributes given as name and value pairs.
+func (s *span) set(attrs ...string) {
+	if s == nil {
+		return
+	}
+
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs[attrs[i]] = attrs[i+1]
+	}
+}
+
+// end finishes a span and queues it for sending. The span is dropped if the queue is full.
+func (s *span) end(err error) {
+	t := tracer
+	if s == nil || t == nil {
+		return
+	}
+
+	o := otlpSpan{
+		TraceID:      s.traceID,
+		SpanID:       s.spanID,
+		ParentSpanID: s.parent,
+		Name:         s.name,
+		Kind:         s.kind,
+		Start:        strconv.FormatInt(s.start.UnixNano(), 10),
+		End:          strconv.FormatInt(time.Now().UnixNano(), 10),
+		Status:       otlpStatus{Code: statusOk},
+	}
+
+	for name, value := range s.attrs {
+		o.Attributes = append(o.Attributes, otlpAttribute{Key: name, Value: otlpValue{String: value}})
+	}
+
+	if err != nil {
+		o.Status = otlpStatus{Code: statusError, Message: redact(err.Error())}
+	}
+
+	select {
+	case t.spans <- o:
+	default:
+	}
+}
+
+// run sends queued spans until the context is cancelled, the remaining spans are sent before it returns.
+func (e *spanExporter) run(ctx context.Context) {
+	ticker := time.NewTicker(traceInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpSpan, 0, traceBatchSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for len(e.spans) > 0 && len(batch) < traceBatchSize {
+				batch = append(batch, <-e.spans)
+			}
+			e.send(batch)
+			return
+		case s := <-e.spans:
+			if batch = append(batch, s); len(batch) == traceBatchSize {
+				e.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// send posts a batch of spans to the collector. Errors are logged only.
+func (e *spanExporter) send(batch []otlpSpan) {
+	if len(batch) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{{Key: "service.name", Value: otlpValue{String: traceService}}}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "mysql"},
+			Spans: batch,
+		}},
+	}}})
+	if err != nil {
+		impl.Errf("cannot encode trace spans: %s", err)
+		return
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		impl.Debugf("Cannot send trace spans: %s", redactError(err))
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		impl.Debugf("Cannot send trace spans: the collector returned %s", resp.Status)
+	}
+}
+
+// OTLP/HTTP JSON encoding of spans.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID      string          `json:"traceId"`
+		SpanID       string          `json:"spanId"`
+		ParentSpanID string          `json:"parentSpanId,omitempty"`
+		Name         string          `json:"name"`
+		Kind         int             `json:"kind"`
+		Start        string          `json:"startTimeUnixNano"`
+		End          string          `json:"endTimeUnixNano"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+		Status       otlpStatus      `json:"status"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpValue struct {
+		String string `json:"stringValue"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
@@ -0,0 +1,267 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Representations of NULL columns.
+const (
+	nullEmpty = "empty"
+	nullJSON  = "null"
+	nullOmit  = "omit"
+)
+
+// Encodings of binary columns.
+const (
+	binaryBase64 = "base64"
+	binaryHex    = "hex"
+)
+
+// rowFormat defines how values of typed rows are represented.
+type rowFormat struct {
+	numbers bool
+	null    string
+	binary  string
+	maxRows int
+}
+
+// binaryTypes lists database types of columns holding binary strings, which are not valid UTF-8 in general.
+var binaryTypes = map[string]bool{
+	"BINARY":     true,
+	"VARBINARY":  true,
+	"TINYBLOB":   true,
+	"BLOB":       true,
+	"MEDIUMBLOB": true,
+	"LONGBLOB":   true,
+	"BIT":        true,
+	"GEOMETRY":   true,
+}
+
+// numericTypes lists database types of columns emitted as JSON numbers, unsigned types are listed without the prefix.
+var numericTypes = map[string]bool{
+	"TINYINT":   true,
+	"SMALLINT":  true,
+	"MEDIUMINT": true,
+	"INT":       true,
+	"BIGINT":    true,
+	"YEAR":      true,
+	"DECIMAL":   true,
+	"FLOAT":     true,
+	"DOUBLE":    true,
+}
+
+// typedValue converts a value scanned from the driver to a JSON number if the column has a numeric type.
+// Numbers are kept as text, so decimals and unsigned integers do not lose precision.
+func typedValue(value interface{}, dbType string) interface{} {
+	s := valueToString(value)
+
+	if !numericTypes[strings.TrimPrefix(dbType, "UNSIGNED ")] {
+		return s
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return s
+	}
+
+	return json.Number(s)
+}
+
+// encodeBinary encodes a value of a binary column as text.
+func encodeBinary(value interface{}, encoding string) string {
+	b, ok := value.([]byte)
+	if !ok {
+		b = []byte(valueToString(value))
+	}
+
+	if encoding == binaryHex {
+		return hex.EncodeToString(b)
+	}
+
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// value returns the representation of a value scanned from a column of a database type, it is not set if the column
+// is NULL and NULL columns are omitted.
+func (f rowFormat) value(value interface{}, dbType string) (v interface{}, ok bool) {
+	switch {
+	case value != nil && binaryTypes[dbType]:
+		return encodeBinary(value, f.binary), true
+	case value != nil && f.numbers:
+		return typedValue(value, dbType), true
+	case value != nil || f.null == nullEmpty:
+		return valueToString(value), true
+	case f.null == nullJSON:
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// rows2typed is rows2data with numbers and NULL columns represented according to a format.
+func rows2typed(rows *sql.Rows, format rowFormat) (result []map[string]interface{}, err error) {
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	count := len(columns)
+	tableData := make([]map[string]interface{}, 0)
+	values := make([]interface{}, count)
+	valuePtrs := make([]interface{}, count)
+
+	for i := 0; i < count; i++ {
+		valuePtrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		// Rows above the limit are not scanned, so a large result does not build up in memory.
+		if format.maxRows > 0 && len(tableData) == format.maxRows {
+			return nil, limitError{option: "MaxRows", limit: format.maxRows, unit: "rows"}
+		}
+
+		if err = rows.Scan(valuePtrs...); err != nil {
+			return
+		}
+
+		entry := make(map[string]interface{})
+		for i, col := range columns {
+			if v, ok := format.value(values[i], col.DatabaseTypeName()); ok {
+				entry[col.Name()] = v
+			}
+		}
+
+		tableData = append(tableData, entry)
+	}
+
+	return tableData, rows.Err()
+}
+
+// queryTyped is queryContext returning typed rows.
+func queryTyped(ctx context.Context, conn *dbConn, query string, format rowFormat, args ...interface{}) (data []map[string]interface{}, err error) {
+	err = runQuery(ctx, conn, query, func(rows *sql.Rows) (int, error) {
+		data, err = rows2typed(rows, format)
+		return len(data), err
+	}, args...)
+
+	return data, err
+}
+
+// singleRowErrors lists JSON metrics returning the first row as an object with the error if there are no rows.
+var singleRowErrors = map[string]error{
+	"mysql.replication.get_replica_status": errorNoReplication,
+	"mysql.master_status":                  errorNoBinlog,
+	"mysql.group_replication.member":       errorNoGroupMember,
+	"mysql.innodb_cluster":                 errorNotInCluster,
+}
+
+// getTypedJSON is getJSON for metrics returning rows.
+func (p *Plugin) getTypedJSON(ctx context.Context, config *dbConn, key, query string, args ...interface{}) (result interface{}, rows int, err error) {
+	format := rowFormat{
+		numbers: p.options.TypedJSON == 1,
+		null:    p.options.NullValue,
+		binary:  p.options.BinaryEncoding,
+		maxRows: p.options.MaxRows,
+	}
+
+	tableData, err := queryTyped(ctx, config, query, format, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if isReplicaStatus(key) {
+		legacyTypedRows(tableData)
+	}
+
+	_, marshalSpan := startSpan(ctx, "marshal")
+	defer func() { marshalSpan.end(err) }()
+
+	var data interface{} = tableData
+	if noRows, ok := singleRowErrors[key]; ok {
+		if len(tableData) == 0 {
+			return nil, 0, noRows
+		}
+		if set, ok := tableData[0]["Executed_Gtid_Set"].(string); ok {
+			tableData[0]["Executed_Gtid_Set"] = gtidSet(set)
+		}
+		data = tableData[0]
+	}
+
+	jsonData, err := json.Marshal(p.filterFields(key, data))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = p.checkSize(jsonData); err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(tableData), nil
+}
+
+// getNumericRows returns rows of a query as a JSON array with numeric columns as numbers regardless of TypedJSON,
+// it is used by metrics which have reported numbers from the beginning.
+func (p *Plugin) getNumericRows(ctx context.Context, conn *dbConn, query string, args ...interface{}) (interface{}, int, error) {
+	format := rowFormat{numbers: true, null: p.options.NullValue, binary: p.options.BinaryEncoding, maxRows: p.options.MaxRows}
+
+	tableData, err := queryTyped(ctx, conn, query, format, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	jsonData, err := json.Marshal(tableData)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err = p.checkSize(jsonData); err != nil {
+		return nil, 0, err
+	}
+
+	return string(jsonData), len(tableData), nil
+}
+
+// getNumericObject is getNumericRows for queries returning one row, which is returned as a JSON object.
+func (p *Plugin) getNumericObject(ctx context.Context, conn *dbConn, query string, args ...interface{}) (interface{}, error) {
+	format := rowFormat{numbers: true, null: p.options.NullValue, binary: p.options.BinaryEncoding}
+
+	tableData, err := queryTyped(ctx, conn, query, format, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tableData) == 0 {
+		return nil, errorEmptyResult
+	}
+
+	jsonData, err := json.Marshal(tableData[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
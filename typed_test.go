@@ -0,0 +1,100 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTypedValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		dbType   string
+		expected interface{}
+	}{
+		{[]byte("42"), "INT", json.Number("42")},
+		{[]byte("-1"), "BIGINT", json.Number("-1")},
+		{[]byte("18446744073709551615"), "UNSIGNED BIGINT", json.Number("18446744073709551615")},
+		{[]byte("12345678901234567890.123456789"), "DECIMAL", json.Number("12345678901234567890.123456789")},
+		{[]byte("1.5e-7"), "DOUBLE", json.Number("1.5e-7")},
+		{[]byte("2024"), "YEAR", json.Number("2024")},
+		{int64(7), "BIGINT", json.Number("7")},
+		{[]byte("42"), "VARCHAR", "42"},
+		{[]byte("-"), "DECIMAL", "-"},
+		{[]byte("2024-01-01"), "DATE", "2024-01-01"},
+	}
+
+	for _, tt := range tests {
+		if v := typedValue(tt.value, tt.dbType); v != tt.expected {
+			t.Errorf("typedValue(%q, %s) = %#v, expected %#v", tt.value, tt.dbType, v, tt.expected)
+		}
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		encoding string
+		expected string
+	}{
+		{[]byte{0xff, 0x00, 0x10}, binaryBase64, "/wAQ"},
+		{[]byte{0xff, 0x00, 0x10}, binaryHex, "ff0010"},
+		{[]byte{}, binaryHex, ""},
+		{"abc", binaryBase64, "YWJj"},
+		{"abc", binaryHex, "616263"},
+	}
+
+	for _, tt := range tests {
+		if s := encodeBinary(tt.value, tt.encoding); s != tt.expected {
+			t.Errorf("encodeBinary(%q, %s) = %q, expected %q", tt.value, tt.encoding, s, tt.expected)
+		}
+	}
+}
+
+func TestRowFormatValue(t *testing.T) {
+	tests := []struct {
+		format   rowFormat
+		value    interface{}
+		dbType   string
+		expected interface{}
+		ok       bool
+	}{
+		{rowFormat{null: nullEmpty}, nil, "INT", "", true},
+		{rowFormat{null: nullJSON}, nil, "INT", nil, true},
+		{rowFormat{null: nullOmit}, nil, "INT", nil, false},
+		{rowFormat{numbers: true, null: nullJSON}, nil, "INT", nil, true},
+		{rowFormat{numbers: true, null: nullOmit}, nil, "BLOB", nil, false},
+		{rowFormat{null: nullEmpty}, []byte("42"), "INT", "42", true},
+		{rowFormat{numbers: true, null: nullEmpty}, []byte("42"), "INT", json.Number("42"), true},
+		{rowFormat{numbers: true, null: nullEmpty}, []byte("abc"), "VARCHAR", "abc", true},
+		{rowFormat{binary: binaryHex}, []byte{0x01, 0xab}, "VARBINARY", "01ab", true},
+		{rowFormat{binary: binaryBase64}, []byte{0x01, 0xab}, "BLOB", "Aas=", true},
+		{rowFormat{numbers: true, binary: binaryHex}, []byte{0x01}, "BIT", "01", true},
+	}
+
+	for _, tt := range tests {
+		v, ok := tt.format.value(tt.value, tt.dbType)
+		if ok != tt.ok || !reflect.DeepEqual(v, tt.expected) {
+			t.Errorf("%+v.value(%q, %s) = %#v, %t, expected %#v, %t", tt.format, tt.value, tt.dbType, v, ok, tt.expected, tt.ok)
+		}
+	}
+}
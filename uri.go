@@ -21,11 +21,33 @@ package mysql
 
 import (
 	"net/url"
-	"time"
+	"strings"
 
 	"github.com/go-sql-driver/mysql"
 )
 
+// normalizeURI adds a scheme to a URI given as a bare host:port or a path to a Unix-socket.
+func normalizeURI(uri string) string {
+	if len(uri) == 0 || strings.Contains(uri, "://") {
+		return uri
+	}
+
+	if strings.HasPrefix(uri, "/") {
+		return "unix://" + uri
+	}
+
+	return "tcp://" + uri
+}
+
+// normalizeURIs adds missing schemes to the default URI and URIs of all sessions.
+func normalizeURIs(opts *PluginOptions) {
+	opts.Uri = normalizeURI(opts.Uri)
+
+	for _, s := range opts.Sessions {
+		s.Uri = normalizeURI(s.Uri)
+	}
+}
+
 func checkURI(s *Session) (sessionURL *url.URL, err error) {
 
 	sessionURL, err = url.Parse(s.Uri)
@@ -38,11 +60,33 @@ func checkURI(s *Session) (sessionURL *url.URL, err error) {
 		if len(sessionURL.Host) == 0 {
 			return nil, errorParameterNotURI
 		}
+		for _, host := range strings.Split(sessionURL.Host, ",") {
+			if len(host) == 0 {
+				return nil, errorParameterNotURI
+			}
+		}
+	case srvNet:
+		if len(sessionURL.Hostname()) == 0 || len(sessionURL.Port()) != 0 {
+			return nil, errorParameterNotURI
+		}
 	case "unix":
+		if !unixSupported {
+			return nil, errorNetworkPlatform
+		}
 		if len(sessionURL.Path) == 0 {
 			return nil, errorParameterNotURI
 		}
 		sessionURL.Host = sessionURL.Path
+	case pipeNet:
+		if !pipeSupported {
+			return nil, errorNetworkPlatform
+		}
+		// Only local pipes are supported: pipe:///name or pipe://./name.
+		name := strings.TrimPrefix(sessionURL.Path, "/")
+		if (len(sessionURL.Host) != 0 && sessionURL.Host != ".") || len(name) == 0 || strings.Contains(name, "/") {
+			return nil, errorParameterNotURI
+		}
+		sessionURL.Host = name
 	default:
 		return nil, errorParameterNotURI
 	}
@@ -57,15 +101,75 @@ func (p *Plugin) getConfigDSN(s *Session) (result *mysql.Config, err error) {
 		return nil, err
 	}
 
+	network := sessionURL.Scheme
+	if network == "tcp" && strings.Contains(sessionURL.Host, ",") {
+		network = failoverNet
+	}
+
+	if len(s.SSHHost) > 0 || len(s.Proxy) > 0 {
+		if network != "tcp" {
+			return nil, errorTunnelNetwork
+		}
+	}
+
+	if len(s.SSHHost) > 0 {
+		if network, err = tunnels.network(s); err != nil {
+			return nil, err
+		}
+	} else if len(s.Proxy) > 0 {
+		if network, err = proxies.network(s.Proxy); err != nil {
+			return nil, err
+		}
+	}
+
 	result = &mysql.Config{
 		User:                 s.User,
 		Passwd:               s.Password,
-		Net:                  sessionURL.Scheme,
+		Net:                  network,
 		Addr:                 sessionURL.Host,
 		AllowNativePasswords: true,
-		Timeout:              time.Duration(p.options.Timeout-1) * time.Second,
-		ReadTimeout:          time.Duration(p.options.Timeout-1) * time.Second,
+		Timeout:              p.budget.connect,
+		ReadTimeout:          p.budget.query,
+	}
+
+	if s.Compress == 1 {
+		if err = result.Apply(mysql.EnableCompression(true)); err != nil {
+			return nil, err
+		}
+	}
+
+	return applyParams(result, s.Params)
+}
+
+// splitStatements splits a semicolon separated list of SQL statements.
+func splitStatements(list string) (result []string) {
+	for _, stmt := range strings.Split(list, ";") {
+		if stmt = strings.TrimSpace(stmt); len(stmt) > 0 {
+			result = append(result, stmt)
+		}
 	}
 
 	return
 }
+
+// applyParams merges DSN parameters into a driver config.
+// The parameters are parsed by the driver itself, so any of its parameters can be used.
+func applyParams(config *mysql.Config, params map[string]string) (*mysql.Config, error) {
+	if len(params) == 0 {
+		return config, nil
+	}
+
+	values := url.Values{}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+
+	dsn := config.FormatDSN()
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+
+	return mysql.ParseDSN(dsn + values.Encode())
+}
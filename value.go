@@ -0,0 +1,60 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// valueType defines the type of a value returned by a scalar metric.
+type valueType int
+
+const (
+	valueText valueType = iota
+	valueInt
+	valueFloat
+)
+
+// valueToString converts a value scanned from the driver to a string.
+func valueToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+
+	return fmt.Sprint(value)
+}
+
+// convertValue converts a string value to the type declared by a metric.
+func convertValue(value string, vt valueType) (interface{}, error) {
+	switch vt {
+	case valueInt:
+		return strconv.ParseInt(value, 10, 64)
+	case valueFloat:
+		return strconv.ParseFloat(value, 64)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,89 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"strings"
+)
+
+// likeEscaper escapes wildcards of a LIKE pattern, so a name is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// getVariable returns the value of a single variable queried by a SHOW ... LIKE statement.
+func getVariable(ctx context.Context, conn *dbConn, query, name string) (interface{}, error) {
+	variables, err := queryVariables(ctx, conn, query, likeEscaper.Replace(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return lookupVariable(variables, name)
+}
+
+// lookupVariable finds a variable by its name, which is case-insensitive like in MySQL.
+func lookupVariable(variables map[string]string, name string) (interface{}, error) {
+	if value, ok := variables[name]; ok {
+		return value, nil
+	}
+
+	for n, value := range variables {
+		if strings.EqualFold(n, name) {
+			return value, nil
+		}
+	}
+
+	return nil, errorUnknownVariable
+}
+
+// States reported by mysql.read_only.
+const (
+	readWrite = iota
+	readOnly
+	superReadOnly
+)
+
+// readOnlyQuery returns both variables, super_read_only is missing on MariaDB and MySQL before 5.7.
+const readOnlyQuery = "show global variables where variable_name in ('read_only', 'super_read_only')"
+
+// readOnlyState combines read_only and super_read_only into one state.
+func readOnlyState(variables map[string]string) int {
+	switch {
+	case variables["super_read_only"] == "ON":
+		return superReadOnly
+	case variables["read_only"] == "ON":
+		return readOnly
+	}
+
+	return readWrite
+}
+
+// getReadOnly returns the combined read-only state of a server.
+func getReadOnly(ctx context.Context, conn *dbConn) (interface{}, error) {
+	variables, err := queryVariables(ctx, conn, readOnlyQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := variables["read_only"]; !ok {
+		return nil, errorEmptyResult
+	}
+
+	return readOnlyState(variables), nil
+}
@@ -0,0 +1,97 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// versionJSON is the mode of mysql.version returning a JSON object.
+const versionJSON = "json"
+
+// mariaDBPrefix is prepended to versions of MariaDB 10 by some proxies and replication clients.
+const mariaDBPrefix = "5.5.5-"
+
+// versionInfo is a structured version of a server.
+type versionInfo struct {
+	Flavor         string `json:"flavor"`
+	Version        string `json:"version"`
+	Major          int    `json:"major"`
+	Minor          int    `json:"minor"`
+	Patch          int    `json:"patch"`
+	VersionComment string `json:"version_comment"`
+}
+
+// parseVersion splits the numeric part of a version string like 8.0.21-log or 10.4.12-MariaDB-1:10.4.12+maria~bionic.
+// Missing parts are zero.
+func parseVersion(version string) (major, minor, patch int) {
+	version = strings.TrimPrefix(version, mariaDBPrefix)
+
+	if i := strings.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		version = version[:i]
+	}
+
+	parts := strings.Split(version, ".")
+	numbers := make([]int, 3)
+
+	for i := 0; i < len(parts) && i < len(numbers); i++ {
+		numbers[i], _ = strconv.Atoi(parts[i])
+	}
+
+	return numbers[0], numbers[1], numbers[2]
+}
+
+// getVersionInfo queries the version and the version comment of a server.
+func getVersionInfo(ctx context.Context, conn *dbConn) (*versionInfo, error) {
+	var info versionInfo
+
+	row := conn.connection.QueryRowContext(ctx, "select version(), @@version_comment")
+	if err := row.Scan(&info.Version, &info.VersionComment); err != nil {
+		return nil, err
+	}
+
+	flavor, err := serverFlavor(ctx, conn, info.Version, info.VersionComment)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Flavor = flavor
+	info.Major, info.Minor, info.Patch = parseVersion(info.Version)
+
+	return &info, nil
+}
+
+// getVersionJSON returns the structured version of a server as a JSON object.
+func getVersionJSON(ctx context.Context, conn *dbConn) (interface{}, error) {
+	info, err := getVersionInfo(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonData, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(jsonData), nil
+}
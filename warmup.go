@@ -0,0 +1,53 @@
+/*
+** Zabbix
+** Copyright (C) 2001-2019 Zabbix SIA
+**
+** This program is free software; you can redistribute it and/or modify
+** it under the terms of the GNU General Public License as published by
+** the Free Software Foundation; either version 2 of the License, or
+** (at your option) any later version.
+**
+** This program is distributed in the hope that it will be useful,
+** but WITHOUT ANY WARRANTY; without even the implied warranty of
+** MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+** GNU General Public License for more details.
+**
+** You should have received a copy of the GNU General Public License
+** along with this program; if not, write to the Free Software
+** Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+**/
+
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// warmUp establishes connections of all named sessions concurrently in the background.
+// Failures are logged only, the connections will be retried on the first export.
+func (p *Plugin) warmUp() {
+	for name, session := range p.options.Sessions {
+		if session.isTemplate() {
+			continue
+		}
+
+		for _, variant := range session.variants() {
+			mysqlConf, err := p.getConfigDSN(variant)
+			if err != nil {
+				p.Warningf("cannot warm up the session %s: %s", name, redactError(err))
+				continue
+			}
+
+			go func(name string, mysqlConf *mysql.Config, initStatements []string, maxLifetime time.Duration) {
+				if _, err := p.connMgr.GetConnection(context.Background(), mysqlConf, initStatements, maxLifetime, name); err != nil {
+					p.Warningf("cannot warm up the session %s: %s", name, redactError(err))
+					return
+				}
+				p.Debugf("Warmed up the session %s", name)
+			}(name, mysqlConf, splitStatements(variant.InitStatements), variant.maxLifetime())
+		}
+	}
+}